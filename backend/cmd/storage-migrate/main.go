@@ -0,0 +1,87 @@
+// Command storage-migrate copies uploaded files between storage backends,
+// e.g. from the local upload directory to an S3 bucket:
+//
+//	storage-migrate -from local -to s3
+//
+// Both backends are configured with the same environment variables as the
+// server (UPLOAD_DIR, S3_*). Files that already exist at the destination with
+// the same size are skipped unless -overwrite is given. Source files are
+// never deleted.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"synergy_dms/config"
+	"synergy_dms/storage"
+)
+
+func main() {
+	from := flag.String("from", storage.BackendLocal, "source backend (local or s3)")
+	to := flag.String("to", storage.BackendS3, "destination backend (local or s3)")
+	overwrite := flag.Bool("overwrite", false, "copy files that already exist at the destination")
+	dryRun := flag.Bool("dry-run", false, "only report what would be copied")
+	flag.Parse()
+
+	if *from == *to {
+		log.Fatalf("❌ Source and destination must differ")
+	}
+
+	cfg := config.LoadConfig()
+	src, err := storage.New(cfg, *from)
+	if err != nil {
+		log.Fatalf("❌ Failed to open source %s: %v", *from, err)
+	}
+	dst, err := storage.New(cfg, *to)
+	if err != nil {
+		log.Fatalf("❌ Failed to open destination %s: %v", *to, err)
+	}
+
+	ctx := context.Background()
+	var copied, skipped, failed int
+
+	err = src.List(ctx, func(obj storage.Object) error {
+		if !*overwrite {
+			if existing, err := dst.Stat(ctx, obj.Key); err == nil && existing.Size == obj.Size {
+				skipped++
+				return nil
+			}
+		}
+
+		if *dryRun {
+			log.Printf("📄 Would copy %s (%d bytes)", obj.Key, obj.Size)
+			copied++
+			return nil
+		}
+
+		if err := copyObject(ctx, src, dst, obj.Key); err != nil {
+			log.Printf("❌ %s: %v", obj.Key, err)
+			failed++
+			return nil
+		}
+		log.Printf("✅ Copied %s (%d bytes)", obj.Key, obj.Size)
+		copied++
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("❌ Failed to list %s: %v", *from, err)
+	}
+
+	log.Printf("📦 Done: %d copied, %d skipped, %d failed", copied, skipped, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func copyObject(ctx context.Context, src, dst storage.Storage, key string) error {
+	r, obj, err := src.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return dst.Put(ctx, key, r, obj.Size, obj.ContentType)
+}
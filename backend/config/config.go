@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"time"
 )
 
 type Config struct {
@@ -12,6 +14,61 @@ type Config struct {
 	DBName     string
 	JWTSecret  string
 	ServerPort string
+
+	// Token lifetimes
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// Outgoing mail
+	SMTPHost         string
+	SMTPPort         string
+	SMTPUsername     string
+	SMTPPassword     string
+	MailFrom         string
+	AppBaseURL       string
+	PasswordResetTTL time.Duration
+	EmailChangeTTL   time.Duration
+
+	// Login brute-force protection
+	LoginMaxAttempts      int
+	LoginMaxAttemptsPerIP int
+	LoginLockoutDuration  time.Duration
+	LoginBackoffBase      time.Duration
+
+	// Signed download links
+	DownloadSigningKey string
+	DownloadURLTTL     time.Duration
+
+	// File storage
+	StorageBackend string
+	UploadDir      string
+	S3Endpoint     string
+	S3Region       string
+	S3Bucket       string
+	S3AccessKey    string
+	S3SecretKey    string
+	S3UsePathStyle bool
+
+	// Antivirus scanning (empty address disables it)
+	ClamdAddress string
+	ClamdTimeout time.Duration
+	ScanWorkers  int
+
+	// Resumable (tus) uploads
+	ResumableUploadDir     string
+	ResumableUploadTTL     time.Duration
+	ResumableUploadMaxSize int64
+
+	// How often stored blobs are re-hashed
+	IntegrityCheckInterval time.Duration
+
+	// Uploads no document uses are deleted after the grace period
+	OrphanGracePeriod     time.Duration
+	OrphanCleanupInterval time.Duration
+
+	// Background workers rendering upload previews and extracting their text
+	PreviewWorkers   int
+	TextIndexWorkers int
 }
 
 func LoadConfig() *Config {
@@ -23,6 +80,51 @@ func LoadConfig() *Config {
 		DBName:     getEnv("DB_NAME", "synergy_dms"),
 		JWTSecret:  getEnv("JWT_SECRET", "synergy_jwt_secret_key_2024_super_secure"),
 		ServerPort: getEnv("SERVER_PORT", "8080"),
+
+		AccessTokenTTL:  getDurationEnv("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: getDurationEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+
+		SMTPHost:         getEnv("SMTP_HOST", ""),
+		SMTPPort:         getEnv("SMTP_PORT", "1025"),
+		SMTPUsername:     getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:     getEnv("SMTP_PASSWORD", ""),
+		MailFrom:         getEnv("MAIL_FROM", "no-reply@synergy.ru"),
+		AppBaseURL:       getEnv("APP_BASE_URL", "http://localhost:8080"),
+		PasswordResetTTL: getDurationEnv("PASSWORD_RESET_TTL", time.Hour),
+		EmailChangeTTL:   getDurationEnv("EMAIL_CHANGE_TTL", 24*time.Hour),
+
+		LoginMaxAttempts:      getIntEnv("LOGIN_MAX_ATTEMPTS", 5),
+		LoginMaxAttemptsPerIP: getIntEnv("LOGIN_MAX_ATTEMPTS_PER_IP", 20),
+		LoginLockoutDuration:  getDurationEnv("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		LoginBackoffBase:      getDurationEnv("LOGIN_BACKOFF_BASE", time.Second),
+
+		DownloadSigningKey: getEnv("DOWNLOAD_SIGNING_KEY", ""),
+		DownloadURLTTL:     getDurationEnv("DOWNLOAD_URL_TTL", 5*time.Minute),
+
+		StorageBackend: getEnv("STORAGE_BACKEND", "local"),
+		UploadDir:      getEnv("UPLOAD_DIR", "./uploads"),
+		S3Endpoint:     getEnv("S3_ENDPOINT", ""),
+		S3Region:       getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:       getEnv("S3_BUCKET", ""),
+		S3AccessKey:    getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:    getEnv("S3_SECRET_KEY", ""),
+		S3UsePathStyle: getEnv("S3_USE_PATH_STYLE", "true") == "true",
+
+		ClamdAddress: getEnv("CLAMD_ADDRESS", ""),
+		ClamdTimeout: getDurationEnv("CLAMD_TIMEOUT", time.Minute),
+		ScanWorkers:  getIntEnv("SCAN_WORKERS", 2),
+
+		ResumableUploadDir:     getEnv("RESUMABLE_UPLOAD_DIR", "./uploads/.partial"),
+		ResumableUploadTTL:     getDurationEnv("RESUMABLE_UPLOAD_TTL", 24*time.Hour),
+		ResumableUploadMaxSize: int64(getIntEnv("RESUMABLE_UPLOAD_MAX_MB", 500)) * 1024 * 1024,
+
+		IntegrityCheckInterval: getDurationEnv("INTEGRITY_CHECK_INTERVAL", 24*time.Hour),
+
+		OrphanGracePeriod:     getDurationEnv("ORPHAN_GRACE_PERIOD", 72*time.Hour),
+		OrphanCleanupInterval: getDurationEnv("ORPHAN_CLEANUP_INTERVAL", 6*time.Hour),
+
+		PreviewWorkers:   getIntEnv("PREVIEW_WORKERS", 2),
+		TextIndexWorkers: getIntEnv("TEXT_INDEX_WORKERS", 1),
 	}
 }
 
@@ -32,3 +134,21 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+func getIntEnv(key string, defaultValue int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
@@ -1,27 +1,39 @@
 package handlers
 
 import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
 	"synergy_dms/config"
 	"synergy_dms/middleware"
 	"synergy_dms/models"
+	"synergy_dms/services"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+const minPasswordLength = 8
+
 type AuthHandler struct {
-	Config *config.Config
+	Config     *config.Config
+	Mailer     services.Mailer
+	LoginGuard *services.LoginGuard
 }
 
-func NewAuthHandler(cfg *config.Config) *AuthHandler {
-	return &AuthHandler{Config: cfg}
+func NewAuthHandler(cfg *config.Config, mailer services.Mailer, guard *services.LoginGuard) *AuthHandler {
+	return &AuthHandler{Config: cfg, Mailer: mailer, LoginGuard: guard}
 }
 
 type RegisterRequest struct {
-	Email    string          `json:"email"`
-	Password string          `json:"password"`
-	FullName string          `json:"full_name"`
-	Role     models.UserRole `json:"role"`
-	Faculty  string          `json:"faculty"`
+	Email     string          `json:"email"`
+	Password  string          `json:"password"`
+	FullName  string          `json:"full_name"`
+	Role      models.UserRole `json:"role"`
+	FacultyID *uint           `json:"faculty_id"`
+	Faculty   string          `json:"faculty"` // faculty name, accepted for older clients
 }
 
 type LoginRequest struct {
@@ -29,6 +41,24 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
 func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	var req RegisterRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -46,6 +76,13 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		})
 	}
 
+	if len(req.Password) < minPasswordLength {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": fmt.Sprintf("Password must be at least %d characters", minPasswordLength),
+		})
+	}
+
 	// Validate role
 	if req.Role == "" {
 		req.Role = models.RoleStudent
@@ -60,19 +97,30 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 
 	// Check if email already exists
 	var existingUser models.User
-	if err := models.DB.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
+	if err := models.DB.Unscoped().Where("LOWER(email) = LOWER(?)", req.Email).First(&existingUser).Error; err == nil {
 		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
 			"success": false,
 			"message": "Email already registered",
 		})
 	}
 
+	faculty, err := resolveFaculty(req.FacultyID, req.Faculty)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Unknown faculty",
+		})
+	}
+
 	// Create user
 	user := models.User{
 		Email:    req.Email,
 		FullName: req.FullName,
 		Role:     req.Role,
-		Faculty:  req.Faculty,
+		Faculty:  faculty,
+	}
+	if faculty != nil {
+		user.FacultyID = &faculty.ID
 	}
 
 	// Set IsApproved based on role
@@ -98,22 +146,20 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		})
 	}
 
-	// Generate token
-	token, err := middleware.GenerateToken(&user, h.Config)
+	// Start session
+	tokens, err := h.startSession(c, &user)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to generate token",
 		})
 	}
+	tokens["user"] = user.ToResponse()
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"success": true,
 		"message": "Registration successful",
-		"data": fiber.Map{
-			"user":  user.ToResponse(),
-			"token": token,
-		},
+		"data":    tokens,
 	})
 }
 
@@ -133,9 +179,15 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		})
 	}
 
+	// Throttle repeated failures per account and per client IP
+	if wait := h.LoginGuard.Check(req.Email, c.IP()); wait > 0 {
+		return tooManyAttempts(c, wait)
+	}
+
 	// Find user
 	var user models.User
-	if err := models.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+	if err := models.DB.Preload("Faculty").Where("LOWER(email) = LOWER(?)", req.Email).First(&user).Error; err != nil {
+		h.LoginGuard.RecordFailure(req.Email, c.IP(), nil)
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"success": false,
 			"message": "Invalid email or password",
@@ -144,31 +196,188 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 
 	// Check password
 	if !user.CheckPassword(req.Password) {
+		h.LoginGuard.RecordFailure(req.Email, c.IP(), &user.ID)
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"success": false,
 			"message": "Invalid email or password",
 		})
 	}
 
-	// Generate token
-	token, err := middleware.GenerateToken(&user, h.Config)
+	h.LoginGuard.RecordSuccess(req.Email)
+
+	if !user.IsActive {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Your account has been deactivated",
+		})
+	}
+
+	// Second factor required: hand out a short-lived MFA token instead of a session
+	if user.MFAEnabled {
+		mfaToken, err := middleware.GenerateMFAToken(&user, h.Config)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to generate token",
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Two-factor authentication required",
+			"data": fiber.Map{
+				"mfa_required": true,
+				"mfa_token":    mfaToken,
+			},
+		})
+	}
+
+	// Start session
+	tokens, err := h.startSession(c, &user)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to generate token",
 		})
 	}
+	tokens["user"] = user.ToResponse()
 
 	return c.JSON(fiber.Map{
 		"success": true,
 		"message": "Login successful",
+		"data":    tokens,
+	})
+}
+
+// Refresh rotates a refresh token and issues a new access token
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	var req RefreshRequest
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Refresh token is required",
+		})
+	}
+
+	tokenHash := models.HashToken(req.RefreshToken)
+
+	var session models.Session
+	if err := models.DB.Where("refresh_token_hash = ?", tokenHash).First(&session).Error; err != nil {
+		// A rotated-out token being replayed means it leaked: kill the session
+		var reused models.Session
+		if models.DB.Where("previous_token_hash = ?", tokenHash).First(&reused).Error == nil {
+			models.RevokeSession(reused.ID)
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid refresh token",
+		})
+	}
+
+	if !session.IsActive() {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "Session has expired or was revoked",
+		})
+	}
+
+	var user models.User
+	if err := models.DB.Preload("Faculty").First(&user, session.UserID).Error; err != nil || !user.IsActive {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "User not found or deactivated",
+		})
+	}
+
+	refreshToken, err := models.GenerateSecureToken(32)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate token",
+		})
+	}
+
+	// Compare-and-swap on the old hash: of two requests racing with the same
+	// token only one rotates, the other is treated as reuse
+	session.PreviousTokenHash = tokenHash
+	session.RefreshTokenHash = models.HashToken(refreshToken)
+	session.LastUsedAt = time.Now()
+	session.IPAddress = c.IP()
+	result := models.DB.Model(&models.Session{}).
+		Where("id = ? AND refresh_token_hash = ? AND revoked_at IS NULL", session.ID, tokenHash).
+		Updates(map[string]interface{}{
+			"previous_token_hash": session.PreviousTokenHash,
+			"refresh_token_hash":  session.RefreshTokenHash,
+			"last_used_at":        session.LastUsedAt,
+			"ip_address":          session.IPAddress,
+		})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to refresh session",
+		})
+	}
+	if result.RowsAffected == 0 {
+		models.RevokeSession(session.ID)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid refresh token",
+		})
+	}
+
+	token, err := middleware.GenerateToken(&user, &session, h.Config)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate token",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Token refreshed",
 		"data": fiber.Map{
-			"user":  user.ToResponse(),
-			"token": token,
+			"token":         token,
+			"refresh_token": refreshToken,
+			"expires_in":    int(h.Config.AccessTokenTTL.Seconds()),
 		},
 	})
 }
 
+// Logout revokes the current session
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	sessionID := c.Locals("sessionID").(uint)
+
+	if err := models.RevokeSession(sessionID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to log out",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Logged out successfully",
+	})
+}
+
+// LogoutAll revokes every session of the current user
+func (h *AuthHandler) LogoutAll(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	if err := models.RevokeUserSessions(user.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to log out",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Logged out from all sessions",
+	})
+}
+
 func (h *AuthHandler) GetProfile(c *fiber.Ctx) error {
 	user := c.Locals("user").(*models.User)
 
@@ -177,3 +386,229 @@ func (h *AuthHandler) GetProfile(c *fiber.Ctx) error {
 		"data":    user.ToResponse(),
 	})
 }
+
+// ChangePassword changes the password of the logged-in user
+func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	var req ChangePasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Current and new password are required",
+		})
+	}
+
+	if !user.CheckPassword(req.CurrentPassword) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "Current password is incorrect",
+		})
+	}
+
+	if len(req.NewPassword) < minPasswordLength {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": fmt.Sprintf("Password must be at least %d characters", minPasswordLength),
+		})
+	}
+
+	if err := user.HashPassword(req.NewPassword); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to process password",
+		})
+	}
+
+	if err := models.DB.Model(user).Update("password", user.Password).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update password",
+		})
+	}
+
+	// Sign out every other device, keep the current session
+	sessionID := c.Locals("sessionID").(uint)
+	models.DB.Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", user.ID, sessionID).
+		Update("revoked_at", time.Now())
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Password changed successfully",
+	})
+}
+
+// ForgotPassword emails a single-use password reset link.
+// The response is the same whether or not the email is registered.
+func (h *AuthHandler) ForgotPassword(c *fiber.Ctx) error {
+	var req ForgotPasswordRequest
+	if err := c.BodyParser(&req); err != nil || req.Email == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Email is required",
+		})
+	}
+
+	response := fiber.Map{
+		"success": true,
+		"message": "If the email is registered, a reset link has been sent",
+	}
+
+	var user models.User
+	if err := models.DB.Where("LOWER(email) = LOWER(?)", req.Email).First(&user).Error; err != nil {
+		return c.JSON(response)
+	}
+
+	token, err := models.GenerateSecureToken(32)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate reset token",
+		})
+	}
+
+	// Only the most recent reset link stays valid
+	now := time.Now()
+	models.DB.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", user.ID).
+		Update("used_at", now)
+
+	resetToken := models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: models.HashToken(token),
+		ExpiresAt: now.Add(h.Config.PasswordResetTTL),
+	}
+	if err := models.DB.Create(&resetToken).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to create reset token",
+		})
+	}
+
+	body := fmt.Sprintf("Hello, %s!\n\nTo reset your Synergy DMS password, open the link below:\n%s/reset-password?token=%s\n\nThe link expires in %s. If you did not request a reset, ignore this email.",
+		user.FullName, h.Config.AppBaseURL, token, h.Config.PasswordResetTTL)
+	if err := h.Mailer.Send(user.Email, "Synergy DMS password reset", body); err != nil {
+		log.Printf("❌ Failed to send password reset email: %v", err)
+	}
+
+	return c.JSON(response)
+}
+
+// ResetPassword sets a new password using a reset token
+func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
+	var req ResetPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	if req.Token == "" || req.NewPassword == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Token and new password are required",
+		})
+	}
+
+	if len(req.NewPassword) < minPasswordLength {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": fmt.Sprintf("Password must be at least %d characters", minPasswordLength),
+		})
+	}
+
+	var resetToken models.PasswordResetToken
+	if err := models.DB.Preload("User").Where("token_hash = ?", models.HashToken(req.Token)).
+		First(&resetToken).Error; err != nil || !resetToken.IsUsable() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid or expired reset token",
+		})
+	}
+
+	user := resetToken.User
+	if err := user.HashPassword(req.NewPassword); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to process password",
+		})
+	}
+
+	// Consume the token only if nobody else did in the meantime
+	result := models.DB.Model(&models.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", resetToken.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil || result.RowsAffected == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid or expired reset token",
+		})
+	}
+
+	if err := models.DB.Model(&user).Update("password", user.Password).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update password",
+		})
+	}
+
+	models.RevokeUserSessions(user.ID)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Password has been reset. Please log in with the new password",
+	})
+}
+
+// startSession creates a server-side session and returns the token pair for it
+func (h *AuthHandler) startSession(c *fiber.Ctx, user *models.User) (fiber.Map, error) {
+	refreshToken, err := models.GenerateSecureToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: models.HashToken(refreshToken),
+		UserAgent:        c.Get("User-Agent"),
+		IPAddress:        c.IP(),
+		ExpiresAt:        now.Add(h.Config.RefreshTokenTTL),
+		LastUsedAt:       now,
+	}
+	if err := models.DB.Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	token, err := middleware.GenerateToken(user, &session, h.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	return fiber.Map{
+		"token":         token,
+		"refresh_token": refreshToken,
+		"expires_in":    int(h.Config.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// tooManyAttempts responds with 429 and a Retry-After header
+func tooManyAttempts(c *fiber.Ctx, wait time.Duration) error {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"success":     false,
+		"message":     fmt.Sprintf("Too many failed login attempts. Try again in %d seconds", seconds),
+		"retry_after": seconds,
+	})
+}
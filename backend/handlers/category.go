@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"synergy_dms/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type CategoryHandler struct{}
+
+func NewCategoryHandler() *CategoryHandler {
+	return &CategoryHandler{}
+}
+
+type CategoryRequest struct {
+	Name              string                  `json:"name"`
+	Description       string                  `json:"description"`
+	DefaultPriority   models.DocumentPriority `json:"default_priority"`
+	DefaultAssigneeID *uint                   `json:"default_assignee_id"`
+}
+
+// validate trims the request and checks the defaults. It returns the
+// message to report, or "" when the request is valid.
+func (req *CategoryRequest) validate() string {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Description = strings.TrimSpace(req.Description)
+	if req.Name == "" {
+		return "Name is required"
+	}
+	if req.DefaultPriority != 0 && (req.DefaultPriority < models.PriorityLow || req.DefaultPriority > models.PriorityHigh) {
+		return "Invalid default priority. Must be 1, 2 or 3"
+	}
+	if req.DefaultAssigneeID != nil && *req.DefaultAssigneeID == 0 {
+		req.DefaultAssigneeID = nil
+	}
+	if req.DefaultAssigneeID != nil {
+		var assignee models.User
+		if err := models.DB.First(&assignee, *req.DefaultAssigneeID).Error; err != nil {
+			return "Default assignee not found"
+		}
+		if !isAdminRole(assignee.Role) || !assignee.IsApproved || !assignee.IsActive {
+			return "Default assignee must be an active admin"
+		}
+	}
+	return ""
+}
+
+// findCategoryParam loads the category referenced by the :id route parameter
+func findCategoryParam(c *fiber.Ctx) (*models.DocumentCategory, error) {
+	categoryID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid category ID",
+		})
+	}
+
+	var category models.DocumentCategory
+	if err := models.DB.First(&category, categoryID).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Category not found",
+		})
+	}
+	return &category, nil
+}
+
+// GetCategories returns the document categories (used by the document form)
+func (h *CategoryHandler) GetCategories(c *fiber.Ctx) error {
+	var categories []models.DocumentCategory
+	if err := models.DB.Preload("DefaultAssignee").Order("name ASC").Find(&categories).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch categories",
+		})
+	}
+
+	var responses []models.DocumentCategoryResponse
+	for _, category := range categories {
+		responses = append(responses, category.ToResponse())
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    responses,
+		"count":   len(responses),
+	})
+}
+
+// CreateCategory adds a document category (Super-Admin only)
+func (h *CategoryHandler) CreateCategory(c *fiber.Ctx) error {
+	var req CategoryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+	if msg := req.validate(); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": msg,
+		})
+	}
+
+	var count int64
+	models.DB.Unscoped().Model(&models.DocumentCategory{}).Where("name = ?", req.Name).Count(&count)
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "Category with this name already exists",
+		})
+	}
+
+	category := models.DocumentCategory{
+		Name:              req.Name,
+		Description:       req.Description,
+		DefaultPriority:   req.DefaultPriority,
+		DefaultAssigneeID: req.DefaultAssigneeID,
+	}
+	if err := models.DB.Create(&category).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to create category",
+		})
+	}
+
+	models.DB.Preload("DefaultAssignee").First(&category, category.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Category created successfully",
+		"data":    category.ToResponse(),
+	})
+}
+
+// UpdateCategory renames a category or changes its defaults (Super-Admin
+// only). Documents filed earlier keep their priority and assignee.
+func (h *CategoryHandler) UpdateCategory(c *fiber.Ctx) error {
+	category, err := findCategoryParam(c)
+	if category == nil {
+		return err
+	}
+
+	var req CategoryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+	if msg := req.validate(); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": msg,
+		})
+	}
+
+	var count int64
+	models.DB.Unscoped().Model(&models.DocumentCategory{}).
+		Where("name = ? AND id <> ?", req.Name, category.ID).Count(&count)
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "Category with this name already exists",
+		})
+	}
+
+	category.Name = req.Name
+	category.Description = req.Description
+	category.DefaultPriority = req.DefaultPriority
+	category.DefaultAssigneeID = req.DefaultAssigneeID
+	category.DefaultAssignee = nil
+
+	if err := models.DB.Save(category).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update category",
+		})
+	}
+
+	models.DB.Preload("DefaultAssignee").First(category, category.ID)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Category updated successfully",
+		"data":    category.ToResponse(),
+	})
+}
+
+// DeleteCategory retires a category (Super-Admin only). Documents filed
+// under it keep it, but new documents can no longer use it.
+func (h *CategoryHandler) DeleteCategory(c *fiber.Ctx) error {
+	category, err := findCategoryParam(c)
+	if category == nil {
+		return err
+	}
+
+	if err := models.DB.Delete(category).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete category",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Category deleted successfully",
+	})
+}
+
+var errUnknownCategory = errors.New("unknown category")
+
+// resolveCategory loads the category a document is filed under. It returns
+// nil when id is nil or 0.
+func resolveCategory(id *uint) (*models.DocumentCategory, error) {
+	if id == nil || *id == 0 {
+		return nil, nil
+	}
+	var category models.DocumentCategory
+	if err := models.DB.Preload("DefaultAssignee").First(&category, *id).Error; err != nil {
+		return nil, errUnknownCategory
+	}
+	return &category, nil
+}
+
+// normalizeTags cleans up the tags of a request: normalized, without
+// duplicates and sorted. It returns the message to report for bad input.
+func normalizeTags(raw []string) ([]string, string) {
+	seen := map[string]bool{}
+	tags := []string{}
+	for _, name := range raw {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		tag := models.NormalizeTag(name)
+		if tag == "" {
+			return nil, fmt.Sprintf("Tags must be at most %d characters", models.MaxTagLength)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > models.MaxTagsPerDocument {
+		return nil, fmt.Sprintf("A document can have at most %d tags", models.MaxTagsPerDocument)
+	}
+	sort.Strings(tags)
+	return tags, ""
+}
+
+// setDocumentTags replaces the tags of a document
+func setDocumentTags(tx *gorm.DB, document *models.Document, names []string) error {
+	tags, err := models.FindOrCreateTags(tx, names)
+	if err != nil {
+		return err
+	}
+	return tx.Model(document).Association("Tags").Replace(tags)
+}
@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"synergy_dms/config"
+	"synergy_dms/models"
+	"synergy_dms/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const maxCommentLength = 10000
+
+// mentionPattern matches "@user@example.com" mentions in comment bodies
+var mentionPattern = regexp.MustCompile(`(?:^|\s)@([A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,})`)
+
+type CommentHandler struct {
+	Config *config.Config
+	Mailer services.Mailer
+}
+
+func NewCommentHandler(cfg *config.Config, mailer services.Mailer) *CommentHandler {
+	return &CommentHandler{Config: cfg, Mailer: mailer}
+}
+
+type CommentRequest struct {
+	Body       string `json:"body"`
+	ParentID   *uint  `json:"parent_id"`
+	IsInternal bool   `json:"is_internal"`
+}
+
+// canSeeComment reports whether the user may read the comment. Students never
+// see internal comments.
+func canSeeComment(user *models.User, comment *models.Comment) bool {
+	return !comment.IsInternal || isAdminRole(user.Role)
+}
+
+// errMentionUnavailable is returned for any mention that can't be notified
+const errMentionUnavailable = "Mentioned users must exist and be able to see this comment"
+
+// resolveMentions finds the users mentioned in a comment body. Every mentioned
+// user has to be able to read the comment. Unknown addresses and users who
+// cannot see the comment get the same error, so mentions can't be used to
+// probe which emails are registered.
+func resolveMentions(body string, document *models.Document, internal bool) ([]models.User, string) {
+	seen := make(map[string]bool)
+	var emails []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		email := strings.ToLower(strings.TrimRight(match[1], "."))
+		if !seen[email] {
+			seen[email] = true
+			emails = append(emails, email)
+		}
+	}
+	if len(emails) == 0 {
+		return nil, ""
+	}
+
+	var users []models.User
+	if err := models.DB.Where("LOWER(email) IN ? AND is_active = ?", emails, true).Find(&users).Error; err != nil {
+		return nil, "Failed to resolve mentions"
+	}
+
+	found := make(map[string]bool)
+	for i := range users {
+		user := &users[i]
+		found[strings.ToLower(user.Email)] = true
+		if !canViewDocument(user, document) || (internal && !isAdminRole(user.Role)) {
+			return nil, errMentionUnavailable
+		}
+	}
+	for _, email := range emails {
+		if !found[email] {
+			return nil, errMentionUnavailable
+		}
+	}
+
+	return users, ""
+}
+
+// notifyMentions emails users newly mentioned in a comment
+func (h *CommentHandler) notifyMentions(author *models.User, document *models.Document, comment *models.Comment,
+	users []models.User) {
+	for _, user := range users {
+		if user.ID == author.ID {
+			continue
+		}
+		body := fmt.Sprintf("Hello, %s!\n\n%s mentioned you in a comment on \"%s\":\n\n%s\n\n%s/documents/%d",
+			user.FullName, author.FullName, document.Title, comment.Body, h.Config.AppBaseURL, document.ID)
+		if err := h.Mailer.Send(user.Email, "You were mentioned in Synergy DMS", body); err != nil {
+			log.Printf("❌ Failed to send mention notification: %v", err)
+		}
+	}
+}
+
+// findComment loads the :commentId param of a document, hiding internal
+// comments from students
+func findComment(c *fiber.Ctx, user *models.User, document *models.Document) (*models.Comment, error) {
+	commentID, err := strconv.ParseUint(c.Params("commentId"), 10, 32)
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid comment ID",
+		})
+	}
+
+	var comment models.Comment
+	if err := models.DB.Where("document_id = ?", document.ID).First(&comment, commentID).Error; err != nil ||
+		!canSeeComment(user, &comment) {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Comment not found",
+		})
+	}
+
+	return &comment, nil
+}
+
+// GetComments returns the comment threads of a document. Deleted comments
+// stay in place so their replies keep their context.
+func (h *CommentHandler) GetComments(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	document, err := findVisibleDocument(c, user)
+	if document == nil {
+		return err
+	}
+
+	query := models.DB.Unscoped().Preload("Author").Preload("Mentions").Where("document_id = ?", document.ID)
+	if !isAdminRole(user.Role) {
+		query = query.Where("is_internal = ?", false)
+	}
+
+	var comments []models.Comment
+	if err := query.Order("created_at ASC").Find(&comments).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch comments",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    models.BuildCommentThreads(comments),
+		"count":   len(comments),
+	})
+}
+
+// CreateComment adds a comment or a reply to a document
+func (h *CommentHandler) CreateComment(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	document, err := findVisibleDocument(c, user)
+	if document == nil {
+		return err
+	}
+
+	var req CommentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Body == "" || len(req.Body) > maxCommentLength {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": fmt.Sprintf("Comment must be between 1 and %d characters", maxCommentLength),
+		})
+	}
+
+	if req.IsInternal && !isAdminRole(user.Role) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Only admins can write internal comments",
+		})
+	}
+
+	if req.ParentID != nil {
+		var parent models.Comment
+		if err := models.DB.Where("document_id = ?", document.ID).First(&parent, *req.ParentID).Error; err != nil ||
+			!canSeeComment(user, &parent) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Parent comment not found",
+			})
+		}
+		// Replies inside an internal thread stay internal
+		if parent.IsInternal {
+			req.IsInternal = true
+		}
+	}
+
+	mentions, problem := resolveMentions(req.Body, document, req.IsInternal)
+	if problem != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": problem,
+		})
+	}
+
+	comment := models.Comment{
+		DocumentID: document.ID,
+		AuthorID:   user.ID,
+		ParentID:   req.ParentID,
+		Body:       req.Body,
+		IsInternal: req.IsInternal,
+		Mentions:   mentions,
+	}
+
+	if err := models.DB.Omit("Mentions.*").Create(&comment).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to create comment",
+		})
+	}
+	refreshSearchIndex(document.ID)
+
+	go h.notifyMentions(user, document, &comment, mentions)
+
+	models.DB.Preload("Author").Preload("Mentions").First(&comment, comment.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Comment added successfully",
+		"data":    comment.ToResponse(),
+	})
+}
+
+// UpdateComment edits a comment (author only)
+func (h *CommentHandler) UpdateComment(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	document, err := findVisibleDocument(c, user)
+	if document == nil {
+		return err
+	}
+
+	comment, err := findComment(c, user, document)
+	if comment == nil {
+		return err
+	}
+
+	if comment.AuthorID != user.ID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Only the author can edit this comment",
+		})
+	}
+
+	var req CommentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Body == "" || len(req.Body) > maxCommentLength {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": fmt.Sprintf("Comment must be between 1 and %d characters", maxCommentLength),
+		})
+	}
+
+	mentions, problem := resolveMentions(req.Body, document, comment.IsInternal)
+	if problem != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": problem,
+		})
+	}
+
+	var previous []models.User
+	models.DB.Model(comment).Association("Mentions").Find(&previous)
+
+	now := time.Now()
+	comment.Body = req.Body
+	comment.EditedAt = &now
+
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Mentions").Save(comment).Error; err != nil {
+			return err
+		}
+		if err := models.RefreshSearchIndex(tx, document.ID); err != nil {
+			return err
+		}
+		return tx.Model(comment).Association("Mentions").Replace(mentions)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update comment",
+		})
+	}
+
+	// Only users who were not mentioned before get a notification
+	notified := make(map[uint]bool)
+	for _, u := range previous {
+		notified[u.ID] = true
+	}
+	var added []models.User
+	for _, u := range mentions {
+		if !notified[u.ID] {
+			added = append(added, u)
+		}
+	}
+	go h.notifyMentions(user, document, comment, added)
+
+	models.DB.Preload("Author").Preload("Mentions").First(comment, comment.ID)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Comment updated successfully",
+		"data":    comment.ToResponse(),
+	})
+}
+
+// DeleteComment removes a comment (author only). Replies are kept.
+func (h *CommentHandler) DeleteComment(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	document, err := findVisibleDocument(c, user)
+	if document == nil {
+		return err
+	}
+
+	comment, err := findComment(c, user, document)
+	if comment == nil {
+		return err
+	}
+
+	if comment.AuthorID != user.ID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Only the author can delete this comment",
+		})
+	}
+
+	if err := models.DB.Delete(comment).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete comment",
+		})
+	}
+	refreshSearchIndex(document.ID)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Comment deleted successfully",
+	})
+}
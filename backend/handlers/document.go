@@ -1,17 +1,52 @@
 package handlers
 
 import (
+	"errors"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"synergy_dms/models"
+	"synergy_dms/services"
+	"synergy_dms/storage"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-type DocumentHandler struct{}
+type DocumentHandler struct {
+	Workflow *services.WorkflowEngine
+	Versions *services.Versioner
+	Signer   *services.URLSigner
+	Storage  storage.Storage
+	Scans    *services.ScanService
+	Blobs    *services.Blobs
+	Previews *services.PreviewService
+	Indexer  *services.TextIndexer
+}
+
+func NewDocumentHandler(workflow *services.WorkflowEngine, versions *services.Versioner,
+	signer *services.URLSigner, store storage.Storage, scans *services.ScanService,
+	blobs *services.Blobs, previews *services.PreviewService, indexer *services.TextIndexer) *DocumentHandler {
+	return &DocumentHandler{Workflow: workflow, Versions: versions, Signer: signer, Storage: store, Scans: scans,
+		Blobs: blobs, Previews: previews, Indexer: indexer}
+}
 
-func NewDocumentHandler() *DocumentHandler {
-	return &DocumentHandler{}
+// canViewDocument reports whether the user may see the document.
+// Students only see their own documents.
+func canViewDocument(user *models.User, document *models.Document) bool {
+	return user.Role != models.RoleStudent || document.CreatorID == user.ID
+}
+
+// inQueue reports whether the document is in the user's work queue: the
+// documents visibleDocuments lists for them
+func inQueue(user *models.User, document *models.Document) bool {
+	var count int64
+	visibleDocuments(models.DB.Model(&models.Document{}), user).
+		Where("documents.id = ?", document.ID).Count(&count)
+	return count > 0
 }
 
 type CreateDocumentRequest struct {
@@ -19,6 +54,27 @@ type CreateDocumentRequest struct {
 	Description string                  `json:"description"`
 	FilePath    string                  `json:"file_path"`
 	Priority    models.DocumentPriority `json:"priority"`
+	WorkflowID  *uint                   `json:"workflow_id"`
+	CategoryID  *uint                   `json:"category_id"`
+	Tags        []string                `json:"tags"`
+	// Values of the fields the document type defines
+	TypeID       *uint          `json:"type_id"`
+	CustomFields models.JSONMap `json:"custom_fields"`
+}
+
+type UpdateDocumentRequest struct {
+	Title       *string                  `json:"title"`
+	Description *string                  `json:"description"`
+	FilePath    *string                  `json:"file_path"`
+	Priority    *models.DocumentPriority `json:"priority"`
+	// 0 removes the category
+	CategoryID   *uint           `json:"category_id"`
+	Tags         *[]string       `json:"tags"`
+	CustomFields *models.JSONMap `json:"custom_fields"`
+}
+
+type ResubmitRequest struct {
+	Comment string `json:"comment"`
 }
 
 type UpdateStatusRequest struct {
@@ -30,38 +86,94 @@ type DelegateRequest struct {
 	NewAdminID uint `json:"new_admin_id"`
 }
 
-// GetDocuments returns documents based on user role
-func (h *DocumentHandler) GetDocuments(c *fiber.Ctx) error {
-	user := c.Locals("user").(*models.User)
-	var documents []models.Document
-
-	query := models.DB.Preload("Creator").Preload("AssignedTo")
-
+// visibleDocuments limits a document query to what the user may list
+func visibleDocuments(query *gorm.DB, user *models.User) *gorm.DB {
 	switch user.Role {
 	case models.RoleStudent:
 		// Students see only their own documents
-		query = query.Where("creator_id = ?", user.ID)
+		query = query.Where("documents.creator_id = ?", user.ID)
 	case models.RoleAdmin:
-		// Admins see docs assigned to them OR unassigned pending docs
-		query = query.Where("assigned_to_id = ? OR (assigned_to_id IS NULL AND status = ?)",
-			user.ID, models.StatusPending)
+		// Admins see docs assigned to them, unassigned pending docs of their faculties
+		// (docs of students without a faculty go to every admin)
+		// OR docs whose current workflow step they can approve
+		query = query.Where("documents.assigned_to_id = ? OR (documents.assigned_to_id IS NULL AND documents.status = ? AND documents.workflow_id IS NULL AND (documents.faculty_id IS NULL OR documents.faculty_id IN ?)) OR documents.id IN (?)",
+			user.ID, models.StatusPending, user.FacultyScope(), models.ActionableStepDocumentIDs(user))
 	case models.RoleSuperAdmin:
 		// Super-Admins see all documents
 	}
+	return query
+}
+
+// Columns GET /documents may be sorted by
+var documentSortColumns = []string{"id", "title", "status", "priority", "deadline", "created_at", "updated_at"}
+
+// filePathError answers for a file_path that does not point at a file the
+// user uploaded. It returns false for other errors.
+func filePathError(c *fiber.Ctx, err error) (bool, error) {
+	switch {
+	case errors.Is(err, services.ErrNotUploaded):
+		return true, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "File not found, upload it first",
+		})
+	case errors.Is(err, services.ErrUploadNotOwned):
+		return true, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "You can only attach files you uploaded",
+		})
+	}
+	return false, nil
+}
 
-	// Add optional status filter
-	if status := c.Query("status"); status != "" {
-		query = query.Where("status = ?", status)
+// GetDocuments returns a page of the documents the user may see. Besides
+// status and priority it filters by creator, assignee, faculty, creation and
+// deadline dates, overdue deadlines, a part of the title, category, tags,
+// document type and custom fields.
+func (h *DocumentHandler) GetDocuments(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	params, err := parseListParams(c, &models.Document{}, documentSortColumns, "-created_at")
+	if params == nil {
+		return err
 	}
 
-	// Add optional priority filter
+	f := newListFilter(c, visibleDocuments(models.DB.Model(&models.Document{}), user))
+	f.equal("status", "documents.status")
 	if priority := c.Query("priority"); priority != "" {
-		if p, err := strconv.Atoi(priority); err == nil {
-			query = query.Where("priority = ?", p)
+		p, err := strconv.Atoi(priority)
+		if err != nil || models.DocumentPriority(p) < models.PriorityLow || models.DocumentPriority(p) > models.PriorityHigh {
+			f.invalid("priority")
+		} else {
+			f.query = f.query.Where("documents.priority = ?", p)
+		}
+	}
+	f.id("creator_id", "documents.creator_id")
+	f.id("assigned_to_id", "documents.assigned_to_id")
+	f.id("faculty_id", "documents.faculty_id")
+	f.dateRange("created_from", "created_to", "documents.created_at")
+	f.dateRange("deadline_from", "deadline_to", "documents.deadline")
+	f.contains("title", "documents.title")
+	f.id("category_id", "documents.category_id")
+	f.id("type_id", "documents.type_id")
+	f.jsonFields("field.", "documents.custom_fields")
+	// Every listed tag must be present
+	for _, tag := range strings.Split(c.Query("tag"), ",") {
+		if tag = models.NormalizeTag(tag); tag != "" {
+			f.query = f.query.Where("documents.id IN (SELECT document_tags.document_id FROM document_tags "+
+				"JOIN tags ON tags.id = document_tags.tag_id WHERE tags.name = ?)", tag)
 		}
 	}
+	if c.QueryBool("overdue") {
+		// Still waiting for a decision after the deadline
+		f.query = f.query.Where("documents.deadline < ? AND documents.status = ?", time.Now(), models.StatusPending)
+	}
+	if f.bad != "" {
+		return f.respond()
+	}
 
-	if err := query.Order("created_at DESC").Find(&documents).Error; err != nil {
+	var documents []models.Document
+	result, err := fetchPage(f.query, params, &documents, models.PreloadDocumentRelations)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to fetch documents",
@@ -73,11 +185,7 @@ func (h *DocumentHandler) GetDocuments(c *fiber.Ctx) error {
 		responses = append(responses, doc.ToResponse())
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"data":    responses,
-		"count":   len(responses),
-	})
+	return sendPage(c, responses, len(responses), result)
 }
 
 // GetDocument returns a single document by ID
@@ -91,7 +199,7 @@ func (h *DocumentHandler) GetDocument(c *fiber.Ctx) error {
 	}
 
 	var document models.Document
-	if err := models.DB.Preload("Creator").Preload("AssignedTo").First(&document, docID).Error; err != nil {
+	if err := models.PreloadDocumentRelations(models.DB).First(&document, docID).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
 			"message": "Document not found",
@@ -100,7 +208,7 @@ func (h *DocumentHandler) GetDocument(c *fiber.Ctx) error {
 
 	// Check access
 	user := c.Locals("user").(*models.User)
-	if user.Role == models.RoleStudent && document.CreatorID != user.ID {
+	if !canViewDocument(user, &document) {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"success": false,
 			"message": "Access denied",
@@ -132,7 +240,35 @@ func (h *DocumentHandler) CreateDocument(c *fiber.Ctx) error {
 		})
 	}
 
-	// Validate priority
+	category, err := resolveCategory(req.CategoryID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Category not found",
+		})
+	}
+	tags, msg := normalizeTags(req.Tags)
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": msg,
+		})
+	}
+	docType, err := resolveDocumentType(req.TypeID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Document type not found",
+		})
+	}
+	if ok, err := checkCustomFields(c, docType, req.CustomFields); !ok {
+		return err
+	}
+
+	// Validate priority; without one the category default applies
+	if req.Priority == 0 && category != nil {
+		req.Priority = category.DefaultPriority
+	}
 	if req.Priority < 1 || req.Priority > 3 {
 		req.Priority = models.PriorityLow
 	}
@@ -144,26 +280,79 @@ func (h *DocumentHandler) CreateDocument(c *fiber.Ctx) error {
 		Priority:    req.Priority,
 		Status:      models.StatusPending,
 		CreatorID:   user.ID,
+		FacultyID:   user.FacultyID,
+	}
+	if docType != nil {
+		document.TypeID = &docType.ID
+		document.CustomFields = req.CustomFields
+		if document.CustomFields == nil {
+			document.CustomFields = models.JSONMap{}
+		}
+	}
+	if category != nil {
+		document.CategoryID = &category.ID
+		// Workflows pick their own approvers
+		if req.WorkflowID == nil {
+			document.AssignedToID = category.ActiveDefaultAssignee()
+		}
 	}
 
-	if err := models.DB.Create(&document).Error; err != nil {
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&document).Error; err != nil {
+			return err
+		}
+		if err := setDocumentTags(tx, &document, tags); err != nil {
+			return err
+		}
+
+		if req.FilePath != "" {
+			if _, err := h.Versions.AddVersion(tx, &document, req.FilePath, user, ""); err != nil {
+				return err
+			}
+			if err := tx.Omit(clause.Associations).Save(&document).Error; err != nil {
+				return err
+			}
+		}
+
+		// Create history entry
+		history := models.History{
+			DocumentID: document.ID,
+			ActorID:    user.ID,
+			Action:     models.ActionCreated,
+			Comment:    "Document created",
+			Version:    document.CurrentVersion,
+		}
+		if err := tx.Create(&history).Error; err != nil {
+			return err
+		}
+		if err := models.RefreshSearchIndex(tx, document.ID); err != nil {
+			return err
+		}
+
+		if req.WorkflowID != nil {
+			return h.Workflow.Start(tx, &document, *req.WorkflowID, user.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		if handled, err := filePathError(c, err); handled {
+			return err
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, services.ErrWorkflowInactive) ||
+			errors.Is(err, services.ErrWorkflowEmpty) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Workflow not found or not active",
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to create document",
 		})
 	}
 
-	// Create history entry
-	history := models.History{
-		DocumentID: document.ID,
-		ActorID:    user.ID,
-		Action:     models.ActionCreated,
-		Comment:    "Document created",
-	}
-	models.DB.Create(&history)
-
 	// Reload with relations
-	models.DB.Preload("Creator").First(&document, document.ID)
+	models.PreloadDocumentRelations(models.DB).First(&document, document.ID)
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"success": true,
@@ -172,6 +361,251 @@ func (h *DocumentHandler) CreateDocument(c *fiber.Ctx) error {
 	})
 }
 
+// findOwnDocument loads a document by the :id param and checks that the
+// current user created it
+func findOwnDocument(c *fiber.Ctx, user *models.User) (*models.Document, error) {
+	docID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid document ID",
+		})
+	}
+
+	var document models.Document
+	if err := models.DB.First(&document, docID).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Document not found",
+		})
+	}
+
+	if document.CreatorID != user.ID {
+		return nil, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Only the creator can change this document",
+		})
+	}
+
+	return &document, nil
+}
+
+// UpdateDocument lets the creator edit a document while it is pending or rejected
+func (h *DocumentHandler) UpdateDocument(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	document, err := findOwnDocument(c, user)
+	if document == nil {
+		return err
+	}
+
+	if document.Status != models.StatusPending && document.Status != models.StatusRejected {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "Only pending or rejected documents can be edited",
+		})
+	}
+
+	var req UpdateDocumentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	var changed []string
+	if req.Title != nil && *req.Title != document.Title {
+		if *req.Title == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Title is required",
+			})
+		}
+		document.Title = *req.Title
+		changed = append(changed, "title")
+	}
+	if req.Description != nil && *req.Description != document.Description {
+		document.Description = *req.Description
+		changed = append(changed, "description")
+	}
+	newFile := req.FilePath != nil && *req.FilePath != document.FilePath
+	if newFile {
+		if *req.FilePath == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "File cannot be removed, upload a new version instead",
+			})
+		}
+		changed = append(changed, "file")
+	}
+	if req.Priority != nil && *req.Priority != document.Priority {
+		if *req.Priority < models.PriorityLow || *req.Priority > models.PriorityHigh {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid priority. Must be 1, 2 or 3",
+			})
+		}
+		document.Priority = *req.Priority
+		changed = append(changed, "priority")
+	}
+	if req.CategoryID != nil {
+		categoryID := req.CategoryID
+		if *categoryID == 0 {
+			categoryID = nil
+		}
+		if !sameID(categoryID, document.CategoryID) {
+			if _, err := resolveCategory(categoryID); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"success": false,
+					"message": "Category not found",
+				})
+			}
+			document.CategoryID = categoryID
+			changed = append(changed, "category")
+		}
+	}
+	var tags []string
+	if req.Tags != nil {
+		var msg string
+		if tags, msg = normalizeTags(*req.Tags); msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": msg,
+			})
+		}
+		var current []string
+		models.DB.Table("tags").Joins("JOIN document_tags ON document_tags.tag_id = tags.id").
+			Where("document_tags.document_id = ?", document.ID).Order("tags.name ASC").Pluck("tags.name", &current)
+		if !slices.Equal(current, tags) {
+			changed = append(changed, "tags")
+		}
+	}
+	if req.CustomFields != nil && !sameFields(*req.CustomFields, document.CustomFields) {
+		// The type may have been retired since; its schema still applies
+		var docType *models.DocumentType
+		if document.TypeID != nil {
+			docType = &models.DocumentType{}
+			if err := models.DB.Unscoped().First(docType, *document.TypeID).Error; err != nil {
+				docType = nil
+			}
+		}
+		if ok, err := checkCustomFields(c, docType, *req.CustomFields); !ok {
+			return err
+		}
+		document.CustomFields = *req.CustomFields
+		changed = append(changed, "custom fields")
+	}
+
+	if len(changed) > 0 {
+		err = models.DB.Transaction(func(tx *gorm.DB) error {
+			if newFile {
+				if _, err := h.Versions.AddVersion(tx, document, *req.FilePath, user, ""); err != nil {
+					return err
+				}
+			}
+			if err := tx.Save(document).Error; err != nil {
+				return err
+			}
+			if req.Tags != nil {
+				if err := setDocumentTags(tx, document, tags); err != nil {
+					return err
+				}
+			}
+			if err := models.RefreshSearchIndex(tx, document.ID); err != nil {
+				return err
+			}
+			return tx.Create(&models.History{
+				DocumentID: document.ID,
+				ActorID:    user.ID,
+				Action:     models.ActionEdited,
+				Comment:    "Edited: " + strings.Join(changed, ", "),
+				Version:    document.CurrentVersion,
+			}).Error
+		})
+		if handled, err := filePathError(c, err); handled {
+			return err
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to update document",
+			})
+		}
+	}
+
+	// Reload with relations
+	models.PreloadDocumentRelations(models.DB).First(document, document.ID)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Document updated successfully",
+		"data":    document.ToResponse(),
+	})
+}
+
+// ResubmitDocument sends a rejected document back for review. Documents on a
+// workflow start again from its first step.
+func (h *DocumentHandler) ResubmitDocument(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	document, err := findOwnDocument(c, user)
+	if document == nil {
+		return err
+	}
+
+	if document.Status != models.StatusRejected {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "Only rejected documents can be resubmitted",
+		})
+	}
+
+	var req ResubmitRequest
+	// The comment is optional, an empty body is fine
+	_ = c.BodyParser(&req)
+
+	comment := "Document resubmitted"
+	if req.Comment != "" {
+		comment += ": " + req.Comment
+	}
+
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		document.Status = models.StatusPending
+		document.RejectionReason = ""
+		if err := tx.Omit(clause.Associations).Save(document).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(&models.History{
+			DocumentID: document.ID,
+			ActorID:    user.ID,
+			Action:     models.ActionResubmitted,
+			Comment:    comment,
+			Version:    document.CurrentVersion,
+		}).Error; err != nil {
+			return err
+		}
+
+		return h.Workflow.Restart(tx, document, user.ID, "Workflow restarted after resubmission")
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to resubmit document",
+		})
+	}
+
+	// Reload with relations
+	models.PreloadDocumentRelations(models.DB).First(document, document.ID)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Document resubmitted successfully",
+		"data":    document.ToResponse(),
+	})
+}
+
 // UpdateDocumentStatus updates document status (Admin/Super-Admin only)
 func (h *DocumentHandler) UpdateDocumentStatus(c *fiber.Ctx) error {
 	user := c.Locals("user").(*models.User)
@@ -223,6 +657,19 @@ func (h *DocumentHandler) UpdateDocumentStatus(c *fiber.Ctx) error {
 		})
 	}
 
+	// Documents on a workflow move step by step
+	if document.WorkflowID != nil {
+		return h.updateWorkflowStatus(c, user, &document, newStatus, req.Reason)
+	}
+
+	// Admins decide only on documents of their own queue
+	if !inQueue(user, &document) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Access denied",
+		})
+	}
+
 	// Update document
 	document.Status = newStatus
 	if newStatus == models.StatusRejected {
@@ -256,11 +703,12 @@ func (h *DocumentHandler) UpdateDocumentStatus(c *fiber.Ctx) error {
 		ActorID:    user.ID,
 		Action:     action,
 		Comment:    comment,
+		Version:    document.CurrentVersion,
 	}
 	models.DB.Create(&history)
 
 	// Reload with relations
-	models.DB.Preload("Creator").Preload("AssignedTo").First(&document, document.ID)
+	models.PreloadDocumentRelations(models.DB).First(&document, document.ID)
 
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -269,6 +717,74 @@ func (h *DocumentHandler) UpdateDocumentStatus(c *fiber.Ctx) error {
 	})
 }
 
+// updateWorkflowStatus applies a status decision to a document that is on a workflow
+func (h *DocumentHandler) updateWorkflowStatus(c *fiber.Ctx, user *models.User, document *models.Document,
+	newStatus models.DocumentStatus, reason string) error {
+	var err error
+	var outcome services.Outcome
+	message := "Document status updated successfully"
+
+	switch newStatus {
+	case models.StatusApproved:
+		outcome, err = h.Workflow.Approve(document, user, reason)
+	case models.StatusRejected:
+		outcome, err = h.Workflow.Reject(document, user, reason)
+	default:
+		// Re-opening a document restarts its workflow from the first step
+		if user.Role != models.RoleSuperAdmin {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"success": false,
+				"message": "Only super-admins can restart a workflow",
+			})
+		}
+		err = models.DB.Transaction(func(tx *gorm.DB) error {
+			return h.Workflow.Restart(tx, document, user.ID, "Workflow restarted")
+		})
+		message = "Workflow restarted"
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNotStepApprover):
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"success": false,
+				"message": "You are not an approver for the current step",
+			})
+		case errors.Is(err, services.ErrNotAwaitingApproval):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Document is not awaiting approval",
+			})
+		case errors.Is(err, services.ErrAlreadyVoted):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"success": false,
+				"message": "You have already voted on this step",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update document status",
+		})
+	}
+
+	switch outcome {
+	case services.OutcomeVoteRecorded:
+		message = "Vote recorded, waiting for the other approvers"
+	case services.OutcomeStepAdvanced:
+		message = "Step approved, document moved to the next step"
+	}
+
+	// Reload with relations
+	models.PreloadDocumentRelations(models.DB).First(document, document.ID)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": message,
+		"outcome": outcome,
+		"data":    document.ToResponse(),
+	})
+}
+
 // DelegateDocument assigns document to another admin
 func (h *DocumentHandler) DelegateDocument(c *fiber.Ctx) error {
 	user := c.Locals("user").(*models.User)
@@ -319,6 +835,13 @@ func (h *DocumentHandler) DelegateDocument(c *fiber.Ctx) error {
 		})
 	}
 
+	if !targetAdmin.IsActive {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Target admin is deactivated",
+		})
+	}
+
 	var document models.Document
 	if err := models.DB.First(&document, docID).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -327,6 +850,13 @@ func (h *DocumentHandler) DelegateDocument(c *fiber.Ctx) error {
 		})
 	}
 
+	if !inQueue(user, &document) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Access denied",
+		})
+	}
+
 	// Update assignment
 	document.AssignedToID = &req.NewAdminID
 
@@ -347,7 +877,7 @@ func (h *DocumentHandler) DelegateDocument(c *fiber.Ctx) error {
 	models.DB.Create(&history)
 
 	// Reload with relations
-	models.DB.Preload("Creator").Preload("AssignedTo").First(&document, document.ID)
+	models.PreloadDocumentRelations(models.DB).First(&document, document.ID)
 
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -356,7 +886,11 @@ func (h *DocumentHandler) DelegateDocument(c *fiber.Ctx) error {
 	})
 }
 
-// GetDocumentHistory returns audit log for a document
+// Columns GET /documents/:id/history may be sorted by
+var historySortColumns = []string{"id", "timestamp", "action", "version"}
+
+// GetDocumentHistory returns a page of the audit log of a document, filtered
+// by ?action, ?actor_id and ?from / ?to
 func (h *DocumentHandler) GetDocumentHistory(c *fiber.Ctx) error {
 	docID, err := strconv.ParseUint(c.Params("id"), 10, 32)
 	if err != nil {
@@ -377,16 +911,31 @@ func (h *DocumentHandler) GetDocumentHistory(c *fiber.Ctx) error {
 
 	// Check access
 	user := c.Locals("user").(*models.User)
-	if user.Role == models.RoleStudent && document.CreatorID != user.ID {
+	if !canViewDocument(user, &document) {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"success": false,
 			"message": "Access denied",
 		})
 	}
 
+	params, err := parseListParams(c, &models.History{}, historySortColumns, "-timestamp")
+	if params == nil {
+		return err
+	}
+
+	f := newListFilter(c, models.DB.Model(&models.History{}).Where("histories.document_id = ?", docID))
+	f.equal("action", "histories.action")
+	f.id("actor_id", "histories.actor_id")
+	f.dateRange("from", "to", "histories.timestamp")
+	if f.bad != "" {
+		return f.respond()
+	}
+
 	var history []models.History
-	if err := models.DB.Preload("Actor").Where("document_id = ?", docID).
-		Order("timestamp DESC").Find(&history).Error; err != nil {
+	result, err := fetchPage(f.query, params, &history, func(db *gorm.DB) *gorm.DB {
+		return db.Preload("Actor")
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to fetch history",
@@ -398,9 +947,5 @@ func (h *DocumentHandler) GetDocumentHistory(c *fiber.Ctx) error {
 		responses = append(responses, h.ToResponse())
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"data":    responses,
-		"count":   len(responses),
-	})
+	return sendPage(c, responses, len(responses), result)
 }
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"synergy_dms/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Max number of attachments per document
+const maxAttachments = 20
+
+// findAttachment loads the :attachmentId param of a document
+func findAttachment(c *fiber.Ctx, document *models.Document) (*models.DocumentAttachment, error) {
+	attachmentID, err := strconv.ParseUint(c.Params("attachmentId"), 10, 32)
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid attachment ID",
+		})
+	}
+
+	var attachment models.DocumentAttachment
+	if err := models.DB.Preload("UploadedBy").Where("document_id = ?", document.ID).
+		First(&attachment, attachmentID).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Attachment not found",
+		})
+	}
+
+	return &attachment, nil
+}
+
+// GetAttachments lists the files attached to a document. The primary file
+// (FilePath) is returned separately.
+func (h *DocumentHandler) GetAttachments(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	document, err := findVisibleDocument(c, user)
+	if document == nil {
+		return err
+	}
+
+	var attachments []models.DocumentAttachment
+	if err := models.DB.Preload("UploadedBy").Preload("Blob").Where("document_id = ?", document.ID).
+		Order("created_at ASC").Find(&attachments).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch attachments",
+		})
+	}
+
+	responses := []models.DocumentAttachmentResponse{}
+	for _, attachment := range attachments {
+		responses = append(responses, attachment.ToResponse())
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"primary": document.FilePath,
+		"data":    responses,
+		"count":   len(responses),
+	})
+}
+
+// AddAttachments uploads one or more files (multipart field "file") and
+// attaches them to a document. Only the creator can do this while the
+// document is pending or rejected.
+func (h *DocumentHandler) AddAttachments(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	document, err := findOwnDocument(c, user)
+	if document == nil {
+		return err
+	}
+
+	if document.Status != models.StatusPending && document.Status != models.StatusRejected {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "Only pending or rejected documents can be edited",
+		})
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["file"]) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "No file uploaded",
+		})
+	}
+	files := form.File["file"]
+
+	var count int64
+	models.DB.Model(&models.DocumentAttachment{}).Where("document_id = ?", document.ID).Count(&count)
+	if int(count)+len(files) > maxAttachments {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": fmt.Sprintf("A document can have at most %d attachments", maxAttachments),
+		})
+	}
+
+	var attachments []models.DocumentAttachment
+	var uploads []*models.Upload
+	// Files written so far are removed if a later one fails
+	cleanup := func() {
+		for _, upload := range uploads {
+			h.Blobs.Remove(c.UserContext(), upload.StoredName)
+		}
+	}
+
+	for _, file := range files {
+		upload, err := storeUpload(c, file, h.Blobs, h.Scans, user.ID)
+		if err != nil {
+			cleanup()
+			return uploadError(c, err)
+		}
+		uploads = append(uploads, upload)
+
+		attachments = append(attachments, models.DocumentAttachment{
+			DocumentID:    document.ID,
+			StoredName:    upload.StoredName,
+			OriginalName:  upload.OriginalName,
+			MimeType:      upload.MimeType,
+			Size:          upload.Size,
+			Checksum:      upload.Checksum,
+			UploadedByID:  user.ID,
+			ScanStatus:    upload.ScanStatus,
+			ScanSignature: upload.ScanSignature,
+		})
+	}
+
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&attachments).Error; err != nil {
+			return err
+		}
+		if err := models.RefreshSearchIndex(tx, document.ID); err != nil {
+			return err
+		}
+
+		names := make([]string, 0, len(attachments))
+		for _, attachment := range attachments {
+			names = append(names, attachment.OriginalName)
+		}
+		return tx.Create(&models.History{
+			DocumentID: document.ID,
+			ActorID:    user.ID,
+			Action:     models.ActionAttached,
+			Comment:    "Attached: " + strings.Join(names, ", "),
+			Version:    document.CurrentVersion,
+		}).Error
+	})
+	if err != nil {
+		cleanup()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to attach files",
+		})
+	}
+
+	for _, upload := range uploads {
+		h.Scans.Enqueue(upload.ID)
+		h.Previews.Enqueue(upload.Checksum)
+		h.Indexer.Enqueue(upload.Checksum)
+	}
+
+	var responses []models.DocumentAttachmentResponse
+	for _, attachment := range attachments {
+		attachment.UploadedBy = *user
+		responses = append(responses, attachment.ToResponse())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Files attached successfully",
+		"data":    responses,
+	})
+}
+
+// DownloadAttachment sends an attached file under its original name
+func (h *DocumentHandler) DownloadAttachment(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	document, err := findVisibleDocument(c, user)
+	if document == nil {
+		return err
+	}
+
+	attachment, err := findAttachment(c, document)
+	if attachment == nil {
+		return err
+	}
+
+	return h.serveDocumentFile(c, document, strconv.FormatUint(uint64(attachment.ID), 10))
+}
+
+// DeleteAttachment detaches a file from a document and removes it. Only the
+// creator can do this while the document is pending or rejected.
+func (h *DocumentHandler) DeleteAttachment(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	document, err := findOwnDocument(c, user)
+	if document == nil {
+		return err
+	}
+
+	if document.Status != models.StatusPending && document.Status != models.StatusRejected {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "Only pending or rejected documents can be edited",
+		})
+	}
+
+	attachment, err := findAttachment(c, document)
+	if attachment == nil {
+		return err
+	}
+
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(attachment).Error; err != nil {
+			return err
+		}
+		if err := models.RefreshSearchIndex(tx, document.ID); err != nil {
+			return err
+		}
+		return tx.Create(&models.History{
+			DocumentID: document.ID,
+			ActorID:    user.ID,
+			Action:     models.ActionDetached,
+			Comment:    "Detached: " + attachment.OriginalName,
+			Version:    document.CurrentVersion,
+		}).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to detach file",
+		})
+	}
+
+	if err := h.Blobs.Remove(c.UserContext(), attachment.StoredName); err != nil {
+		log.Printf("⚠️  Failed to delete attachment file %s: %v", attachment.StoredName, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "File detached successfully",
+	})
+}
@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"synergy_dms/models"
+	"synergy_dms/services"
+	"synergy_dms/storage"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// FilePrimary addresses the current main file of a document
+const FilePrimary = "primary"
+
+var errFileNotFound = errors.New("file not found")
+
+// inlineTypes may be displayed in the browser; everything else is always
+// sent as a download
+var inlineTypes = map[string]bool{
+	"application/pdf": true,
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"text/plain":      true,
+}
+
+// documentFile is a stored file that belongs to a document
+type documentFile struct {
+	Key        string
+	Name       string
+	MimeType   string
+	Checksum   string
+	ScanStatus models.ScanStatus
+}
+
+// resolveDocumentFile finds a file of the document. fileID is "primary" for
+// the current file, "v<N>" for a file version or an attachment ID.
+func (h *DocumentHandler) resolveDocumentFile(document *models.Document, fileID string) (*documentFile, error) {
+	var storedPath, name, mimeType string
+
+	switch {
+	case fileID == FilePrimary || strings.HasPrefix(fileID, "v"):
+		number := document.CurrentVersion
+		if fileID != FilePrimary {
+			n, err := strconv.Atoi(strings.TrimPrefix(fileID, "v"))
+			if err != nil {
+				return nil, errFileNotFound
+			}
+			number = n
+		}
+
+		storedPath = document.FilePath
+		if number > 0 {
+			var version models.DocumentVersion
+			if err := models.DB.Where("document_id = ? AND version = ?", document.ID, number).
+				First(&version).Error; err != nil {
+				return nil, errFileNotFound
+			}
+			storedPath = version.FilePath
+		}
+
+	default:
+		attachmentID, err := strconv.ParseUint(fileID, 10, 32)
+		if err != nil {
+			return nil, errFileNotFound
+		}
+		var attachment models.DocumentAttachment
+		if err := models.DB.Where("document_id = ?", document.ID).First(&attachment, attachmentID).Error; err != nil {
+			return nil, errFileNotFound
+		}
+		storedPath = models.UploadURLPrefix + attachment.StoredName
+		name = attachment.OriginalName
+		mimeType = attachment.MimeType
+	}
+
+	key, err := services.StorageKey(storedPath)
+	if err != nil {
+		return nil, errFileNotFound
+	}
+
+	file := &documentFile{Key: key, Name: name, MimeType: mimeType, ScanStatus: models.ScanSkipped}
+	if upload := models.FindUpload(key); upload != nil {
+		file.Key = upload.StorageKey()
+		file.Checksum = upload.Checksum
+		file.ScanStatus = upload.ScanStatus
+		if file.Name == "" {
+			file.Name = upload.OriginalName
+		}
+		if file.MimeType == "" {
+			file.MimeType = upload.MimeType
+		}
+	}
+
+	// Legacy files have no upload record: fall back to the stored name and
+	// a type guessed from the extension
+	if file.Name == "" {
+		file.Name = filepath.Base(key)
+	}
+	if file.MimeType == "" {
+		file.MimeType = mime.TypeByExtension(strings.ToLower(filepath.Ext(key)))
+	}
+	if file.MimeType == "" {
+		file.MimeType = "application/octet-stream"
+	}
+	return file, nil
+}
+
+// contentDisposition builds the Content-Disposition header of a file.
+// Only safe types are shown inline.
+func (f *documentFile) contentDisposition(inline bool) string {
+	disposition := "attachment"
+	mediaType, _, _ := mime.ParseMediaType(f.MimeType)
+	if inline && inlineTypes[mediaType] {
+		disposition = "inline"
+	}
+	return mime.FormatMediaType(disposition, map[string]string{"filename": f.Name})
+}
+
+// checkQuarantine answers for files the antivirus scan has not released.
+// It returns false when the file may be downloaded.
+func checkQuarantine(c *fiber.Ctx, file *documentFile) (bool, error) {
+	switch file.ScanStatus {
+	case models.ScanPending, models.ScanFailed:
+		return true, c.Status(fiber.StatusLocked).JSON(fiber.Map{
+			"success": false,
+			"message": "File is still being scanned for viruses",
+		})
+	case models.ScanInfected:
+		return true, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "File is infected and quarantined",
+		})
+	case models.ScanError:
+		return true, c.Status(fiber.StatusLocked).JSON(fiber.Map{
+			"success": false,
+			"message": "File could not be scanned for viruses and stays quarantined",
+		})
+	}
+	return false, nil
+}
+
+// serveDocumentFile resolves a file and streams it from storage with its type
+// and original name
+func (h *DocumentHandler) serveDocumentFile(c *fiber.Ctx, document *models.Document, fileID string) error {
+	file, err := h.resolveDocumentFile(document, fileID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "File not found",
+		})
+	}
+	if blocked, err := checkQuarantine(c, file); blocked {
+		return err
+	}
+
+	r, obj, err := h.Storage.Get(c.UserContext(), file.Key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "File not found",
+		})
+	}
+	if err != nil {
+		log.Printf("❌ Failed to read %s from storage: %v", file.Key, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to read file",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, file.MimeType)
+	c.Set(fiber.HeaderContentDisposition, file.contentDisposition(c.QueryBool("inline")))
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	return c.SendStream(r, int(obj.Size))
+}
+
+// GetDocumentFile downloads a file of a document with the same access rules
+// as GetDocument. Pass ?inline=true to display PDFs and images in the browser.
+func (h *DocumentHandler) GetDocumentFile(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	document, err := findVisibleDocument(c, user)
+	if document == nil {
+		return err
+	}
+
+	return h.serveDocumentFile(c, document, c.Params("fileId"))
+}
+
+// GetDocumentPreview sends the rendered JPEG preview of a document file.
+// ?file selects the file like GetDocumentFile (default: primary), ?size is
+// "preview" (default) or "thumbnail".
+func (h *DocumentHandler) GetDocumentPreview(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	document, err := findVisibleDocument(c, user)
+	if document == nil {
+		return err
+	}
+
+	size := c.Query("size", models.PreviewSizeLarge)
+	if size != models.PreviewSizeLarge && size != models.PreviewSizeThumbnail {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid preview size",
+		})
+	}
+
+	file, err := h.resolveDocumentFile(document, c.Query("file", FilePrimary))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "File not found",
+		})
+	}
+	if blocked, err := checkQuarantine(c, file); blocked {
+		return err
+	}
+
+	// Files from before deduplication have no blob and no preview
+	status := models.PreviewUnsupported
+	var blob models.Blob
+	if file.Checksum != "" && models.DB.First(&blob, "hash = ?", file.Checksum).Error == nil {
+		status = blob.PreviewStatus
+	}
+	if status != models.PreviewReady {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Preview is not available",
+			"data":    fiber.Map{"preview_status": status},
+		})
+	}
+
+	// Previews never change for the same content
+	etag := fmt.Sprintf(`"%s-%s"`, blob.Hash, size)
+	if c.Get(fiber.HeaderIfNoneMatch) == etag {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	r, obj, err := h.Storage.Get(c.UserContext(), models.PreviewKey(blob.Hash, size))
+	if errors.Is(err, storage.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Preview is not available",
+			"data":    fiber.Map{"preview_status": status},
+		})
+	}
+	if err != nil {
+		log.Printf("❌ Failed to read preview of %s from storage: %v", blob.Hash, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to read preview",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "image/jpeg")
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	c.Set(fiber.HeaderCacheControl, "private, no-cache")
+	c.Set(fiber.HeaderETag, etag)
+	return c.SendStream(r, int(obj.Size))
+}
+
+// CreateFileLink issues a short-lived signed URL for a file that can be
+// opened without the Authorization header, e.g. in an <img> or a PDF viewer
+func (h *DocumentHandler) CreateFileLink(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	document, err := findVisibleDocument(c, user)
+	if document == nil {
+		return err
+	}
+
+	fileID := c.Params("fileId")
+	file, err := h.resolveDocumentFile(document, fileID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "File not found",
+		})
+	}
+	if blocked, err := checkQuarantine(c, file); blocked {
+		return err
+	}
+
+	// Object stores hand out their own links so downloads skip the API
+	expires := time.Now().Add(h.Signer.TTL)
+	link, err := h.Storage.SignedURL(c.UserContext(), file.Key, storage.SignedURLOptions{
+		TTL:                h.Signer.TTL,
+		ContentType:        file.MimeType,
+		ContentDisposition: file.contentDisposition(c.QueryBool("inline")),
+	})
+	if err == nil {
+		return c.JSON(fiber.Map{
+			"success": true,
+			"data": fiber.Map{
+				"url":        link,
+				"expires_at": expires,
+			},
+		})
+	}
+	if !errors.Is(err, storage.ErrSignedURLUnsupported) {
+		log.Printf("❌ Failed to sign storage URL for %s: %v", file.Key, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to create download link",
+		})
+	}
+
+	expires, signature := h.Signer.Sign(document.ID, fileID, time.Now())
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", signature)
+	if c.QueryBool("inline") {
+		query.Set("inline", "true")
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"url":        fmt.Sprintf("/files/%d/%s?%s", document.ID, url.PathEscape(fileID), query.Encode()),
+			"expires_at": expires,
+		},
+	})
+}
+
+// DownloadSignedFile serves a file through a signed URL. No session is
+// needed; the signature proves the link was issued to someone with access.
+func (h *DocumentHandler) DownloadSignedFile(c *fiber.Ctx) error {
+	docID, err := strconv.ParseUint(c.Params("docId"), 10, 32)
+	fileID := c.Params("fileId")
+	expires, expErr := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || expErr != nil ||
+		!h.Signer.Verify(uint(docID), fileID, expires, c.Query("signature"), time.Now()) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid or expired link",
+		})
+	}
+
+	var document models.Document
+	if err := models.DB.First(&document, docID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Document not found",
+		})
+	}
+
+	return h.serveDocumentFile(c, &document, fileID)
+}
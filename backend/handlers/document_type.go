@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"synergy_dms/models"
+	"synergy_dms/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type DocumentTypeHandler struct{}
+
+func NewDocumentTypeHandler() *DocumentTypeHandler {
+	return &DocumentTypeHandler{}
+}
+
+type DocumentTypeRequest struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Schema      models.JSONMap `json:"schema"`
+}
+
+// validate trims the request and compiles its schema. It returns the
+// message to report, or "" when the request is valid.
+func (req *DocumentTypeRequest) validate() string {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Description = strings.TrimSpace(req.Description)
+	if req.Name == "" {
+		return "Name is required"
+	}
+	if req.Schema == nil {
+		return "Schema is required"
+	}
+	if _, err := services.CompileFieldSchema(req.Schema); err != nil {
+		return "Invalid schema: " + err.Error()
+	}
+	return ""
+}
+
+// findDocumentTypeParam loads the document type referenced by the :id route
+// parameter
+func findDocumentTypeParam(c *fiber.Ctx) (*models.DocumentType, error) {
+	typeID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid document type ID",
+		})
+	}
+
+	var docType models.DocumentType
+	if err := models.DB.First(&docType, typeID).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Document type not found",
+		})
+	}
+	return &docType, nil
+}
+
+var errUnknownDocumentType = errors.New("unknown document type")
+
+// resolveDocumentType loads the type a new document is created with. It
+// returns nil when id is nil or 0.
+func resolveDocumentType(id *uint) (*models.DocumentType, error) {
+	if id == nil || *id == 0 {
+		return nil, nil
+	}
+	var docType models.DocumentType
+	if err := models.DB.First(&docType, *id).Error; err != nil {
+		return nil, errUnknownDocumentType
+	}
+	return &docType, nil
+}
+
+// checkCustomFields validates custom field values against the document
+// type. It responds with 400 listing the problems and returns false when
+// they do not match.
+func checkCustomFields(c *fiber.Ctx, docType *models.DocumentType, fields models.JSONMap) (bool, error) {
+	if docType == nil {
+		if len(fields) > 0 {
+			return false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Custom fields require a document type",
+			})
+		}
+		return true, nil
+	}
+
+	problems, err := services.ValidateFields(docType, fields)
+	if err != nil {
+		return false, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to validate custom fields",
+		})
+	}
+	if len(problems) > 0 {
+		return false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Custom fields do not match the document type",
+			"errors":  problems,
+		})
+	}
+	return true, nil
+}
+
+// sameFields compares custom field values; no fields and an empty object
+// are the same
+func sameFields(a, b models.JSONMap) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// GetDocumentTypes returns the document types with their schemas (used to
+// build the document form)
+func (h *DocumentTypeHandler) GetDocumentTypes(c *fiber.Ctx) error {
+	var types []models.DocumentType
+	if err := models.DB.Order("name ASC").Find(&types).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch document types",
+		})
+	}
+
+	var responses []models.DocumentTypeResponse
+	for _, docType := range types {
+		responses = append(responses, docType.ToResponse())
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    responses,
+		"count":   len(responses),
+	})
+}
+
+// CreateDocumentType adds a document type (Super-Admin only)
+func (h *DocumentTypeHandler) CreateDocumentType(c *fiber.Ctx) error {
+	var req DocumentTypeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+	if msg := req.validate(); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": msg,
+		})
+	}
+
+	var count int64
+	models.DB.Unscoped().Model(&models.DocumentType{}).Where("name = ?", req.Name).Count(&count)
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "Document type with this name already exists",
+		})
+	}
+
+	docType := models.DocumentType{
+		Name:        req.Name,
+		Description: req.Description,
+		Schema:      req.Schema,
+	}
+	if err := models.DB.Create(&docType).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to create document type",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Document type created successfully",
+		"data":    docType.ToResponse(),
+	})
+}
+
+// UpdateDocumentType renames a type or changes its schema (Super-Admin
+// only). Existing documents are checked against the new schema the next
+// time their fields are edited.
+func (h *DocumentTypeHandler) UpdateDocumentType(c *fiber.Ctx) error {
+	docType, err := findDocumentTypeParam(c)
+	if docType == nil {
+		return err
+	}
+
+	var req DocumentTypeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+	if msg := req.validate(); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": msg,
+		})
+	}
+
+	var count int64
+	models.DB.Unscoped().Model(&models.DocumentType{}).
+		Where("name = ? AND id <> ?", req.Name, docType.ID).Count(&count)
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "Document type with this name already exists",
+		})
+	}
+
+	docType.Name = req.Name
+	docType.Description = req.Description
+	docType.Schema = req.Schema
+
+	if err := models.DB.Save(docType).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update document type",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Document type updated successfully",
+		"data":    docType.ToResponse(),
+	})
+}
+
+// DeleteDocumentType retires a document type (Super-Admin only). Documents
+// of the type keep it and their fields, but new documents can no longer use
+// it.
+func (h *DocumentTypeHandler) DeleteDocumentType(c *fiber.Ctx) error {
+	docType, err := findDocumentTypeParam(c)
+	if docType == nil {
+		return err
+	}
+
+	if err := models.DB.Delete(docType).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete document type",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Document type deleted successfully",
+	})
+}
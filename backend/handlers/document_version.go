@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+
+	"synergy_dms/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// findVisibleDocument loads a document by the :id param and checks that the
+// current user may see it
+func findVisibleDocument(c *fiber.Ctx, user *models.User) (*models.Document, error) {
+	docID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid document ID",
+		})
+	}
+
+	var document models.Document
+	if err := models.DB.First(&document, docID).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Document not found",
+		})
+	}
+
+	if !canViewDocument(user, &document) {
+		return nil, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Access denied",
+		})
+	}
+
+	return &document, nil
+}
+
+// findDocumentVersion loads the :version param of a document
+func findDocumentVersion(c *fiber.Ctx, document *models.Document) (*models.DocumentVersion, error) {
+	number, err := strconv.Atoi(c.Params("version"))
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid version",
+		})
+	}
+
+	var version models.DocumentVersion
+	if err := models.DB.Preload("UploadedBy").
+		Where("document_id = ? AND version = ?", document.ID, number).First(&version).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Version not found",
+		})
+	}
+
+	return &version, nil
+}
+
+// GetDocumentVersions lists every file revision of a document, newest first
+func (h *DocumentHandler) GetDocumentVersions(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	document, err := findVisibleDocument(c, user)
+	if document == nil {
+		return err
+	}
+
+	var versions []models.DocumentVersion
+	if err := models.DB.Preload("UploadedBy").Where("document_id = ?", document.ID).
+		Order("version DESC").Find(&versions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch versions",
+		})
+	}
+
+	var responses []models.DocumentVersionResponse
+	for _, version := range versions {
+		resp := version.ToResponse()
+		resp.IsCurrent = version.Version == document.CurrentVersion
+		responses = append(responses, resp)
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    responses,
+		"count":   len(responses),
+	})
+}
+
+// DownloadDocumentVersion sends the file of a specific revision
+func (h *DocumentHandler) DownloadDocumentVersion(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	document, err := findVisibleDocument(c, user)
+	if document == nil {
+		return err
+	}
+
+	version, err := findDocumentVersion(c, document)
+	if version == nil {
+		return err
+	}
+
+	return h.serveDocumentFile(c, document, "v"+strconv.Itoa(version.Version))
+}
+
+// RestoreDocumentVersion makes an earlier revision current again by adding it
+// as a new version. Only the creator can do this while the document is
+// pending or rejected.
+func (h *DocumentHandler) RestoreDocumentVersion(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	document, err := findOwnDocument(c, user)
+	if document == nil {
+		return err
+	}
+
+	if document.Status != models.StatusPending && document.Status != models.StatusRejected {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "Only pending or rejected documents can be edited",
+		})
+	}
+
+	version, err := findDocumentVersion(c, document)
+	if version == nil {
+		return err
+	}
+
+	if version.Version == document.CurrentVersion {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "This version is already current",
+		})
+	}
+
+	comment := fmt.Sprintf("Restored from version %d", version.Version)
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := models.AddDocumentVersion(tx, document, version.FilePath, version.Size, version.Checksum,
+			user.ID, comment); err != nil {
+			return err
+		}
+		if err := tx.Save(document).Error; err != nil {
+			return err
+		}
+		if err := models.RefreshSearchIndex(tx, document.ID); err != nil {
+			return err
+		}
+		return tx.Create(&models.History{
+			DocumentID: document.ID,
+			ActorID:    user.ID,
+			Action:     models.ActionRestored,
+			Comment:    comment,
+			Version:    document.CurrentVersion,
+		}).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to restore version",
+		})
+	}
+
+	// Reload with relations
+	models.PreloadDocumentRelations(models.DB).First(document, document.ID)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Version restored successfully",
+		"data":    document.ToResponse(),
+	})
+}
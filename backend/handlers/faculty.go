@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"synergy_dms/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type FacultyHandler struct{}
+
+func NewFacultyHandler() *FacultyHandler {
+	return &FacultyHandler{}
+}
+
+type FacultyRequest struct {
+	Name     string `json:"name"`
+	Code     string `json:"code"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+type ManagedFacultiesRequest struct {
+	FacultyIDs []uint `json:"faculty_ids"`
+}
+
+var errUnknownFaculty = errors.New("unknown faculty")
+
+// resolveFaculty finds a faculty by ID or, for older clients, by exact name.
+// It returns nil when neither is given.
+func resolveFaculty(id *uint, name string) (*models.Faculty, error) {
+	var faculty models.Faculty
+
+	switch {
+	case id != nil && *id != 0:
+		if err := models.DB.First(&faculty, *id).Error; err != nil {
+			return nil, errUnknownFaculty
+		}
+	case strings.TrimSpace(name) != "":
+		if err := models.DB.Where("name = ?", strings.TrimSpace(name)).First(&faculty).Error; err != nil {
+			return nil, errUnknownFaculty
+		}
+	default:
+		return nil, nil
+	}
+
+	return &faculty, nil
+}
+
+// GetFaculties returns all faculties and sub-departments (public, used for registration)
+func (h *FacultyHandler) GetFaculties(c *fiber.Ctx) error {
+	var faculties []models.Faculty
+	if err := models.DB.Preload("Parent").Order("name ASC").Find(&faculties).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch faculties",
+		})
+	}
+
+	var responses []models.FacultyResponse
+	for _, faculty := range faculties {
+		responses = append(responses, faculty.ToResponse())
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    responses,
+		"count":   len(responses),
+	})
+}
+
+// CreateFaculty creates a faculty or sub-department (Super-Admin only)
+func (h *FacultyHandler) CreateFaculty(c *fiber.Ctx) error {
+	var req FacultyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Name is required",
+		})
+	}
+
+	if req.ParentID != nil {
+		var parent models.Faculty
+		if err := models.DB.First(&parent, *req.ParentID).Error; err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Parent faculty not found",
+			})
+		}
+	}
+
+	var count int64
+	models.DB.Unscoped().Model(&models.Faculty{}).Where("name = ?", req.Name).Count(&count)
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "Faculty with this name already exists",
+		})
+	}
+
+	faculty := models.Faculty{
+		Name:     req.Name,
+		Code:     strings.TrimSpace(req.Code),
+		ParentID: req.ParentID,
+	}
+
+	if err := models.DB.Create(&faculty).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to create faculty",
+		})
+	}
+
+	models.DB.Preload("Parent").First(&faculty, faculty.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Faculty created successfully",
+		"data":    faculty.ToResponse(),
+	})
+}
+
+// UpdateFaculty renames or moves a faculty (Super-Admin only)
+func (h *FacultyHandler) UpdateFaculty(c *fiber.Ctx) error {
+	facultyID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid faculty ID",
+		})
+	}
+
+	var faculty models.Faculty
+	if err := models.DB.First(&faculty, facultyID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Faculty not found",
+		})
+	}
+
+	var req FacultyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Name is required",
+		})
+	}
+
+	var count int64
+	models.DB.Unscoped().Model(&models.Faculty{}).Where("name = ? AND id <> ?", req.Name, faculty.ID).Count(&count)
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "Faculty with this name already exists",
+		})
+	}
+
+	// A faculty cannot be moved under itself or one of its own sub-departments
+	if req.ParentID != nil {
+		for _, id := range models.ExpandFacultyIDs([]uint{faculty.ID}) {
+			if id == *req.ParentID {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"success": false,
+					"message": "Faculty cannot be its own parent",
+				})
+			}
+		}
+
+		var parent models.Faculty
+		if err := models.DB.First(&parent, *req.ParentID).Error; err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Parent faculty not found",
+			})
+		}
+	}
+
+	faculty.Name = req.Name
+	faculty.Code = strings.TrimSpace(req.Code)
+	faculty.ParentID = req.ParentID
+
+	if err := models.DB.Save(&faculty).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update faculty",
+		})
+	}
+
+	models.DB.Preload("Parent").First(&faculty, faculty.ID)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Faculty updated successfully",
+		"data":    faculty.ToResponse(),
+	})
+}
+
+// DeleteFaculty deletes a faculty nothing refers to any more: no members,
+// sub-departments, documents or workflow steps in use (Super-Admin only)
+func (h *FacultyHandler) DeleteFaculty(c *fiber.Ctx) error {
+	facultyID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid faculty ID",
+		})
+	}
+
+	var faculty models.Faculty
+	if err := models.DB.First(&faculty, facultyID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Faculty not found",
+		})
+	}
+
+	var children, members int64
+	models.DB.Model(&models.Faculty{}).Where("parent_id = ?", faculty.ID).Count(&children)
+	models.DB.Model(&models.User{}).Where("faculty_id = ?", faculty.ID).Count(&members)
+	if children > 0 || members > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "Faculty still has users or sub-departments",
+		})
+	}
+
+	// Documents (including deleted ones) and workflow steps that are still
+	// in use keep pointing at the faculty
+	var documents, steps int64
+	models.DB.Unscoped().Model(&models.Document{}).Where("faculty_id = ?", faculty.ID).Count(&documents)
+	models.DB.Model(&models.WorkflowStep{}).Where("approver_faculty_id = ?", faculty.ID).
+		Where("workflow_id IN (?) OR workflow_id IN (?)",
+			models.DB.Model(&models.WorkflowDefinition{}).Select("id"),
+			models.DB.Model(&models.Document{}).Select("workflow_id").Where("status = ?", models.StatusPending)).
+		Count(&steps)
+	if documents > 0 || steps > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "Faculty is still used by documents or workflow steps",
+		})
+	}
+
+	if err := models.DB.Exec("DELETE FROM admin_faculties WHERE faculty_id = ?", faculty.ID).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete faculty",
+		})
+	}
+
+	if err := models.DB.Delete(&faculty).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete faculty",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Faculty deleted successfully",
+	})
+}
+
+// SetManagedFaculties sets the faculties whose queues an admin works on (Super-Admin only)
+func (h *FacultyHandler) SetManagedFaculties(c *fiber.Ctx) error {
+	actor := c.Locals("user").(*models.User)
+
+	user, err := findUserParam(c, false)
+	if user == nil {
+		return err
+	}
+
+	if !isAdminRole(user.Role) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "User is not an admin",
+		})
+	}
+
+	var req ManagedFacultiesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	faculties := []models.Faculty{}
+	if len(req.FacultyIDs) > 0 {
+		models.DB.Where("id IN ?", req.FacultyIDs).Find(&faculties)
+		if len(faculties) != len(uniqueIDs(req.FacultyIDs)) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "One or more faculties not found",
+			})
+		}
+	}
+
+	if err := models.DB.Model(user).Association("ManagedFaculties").Replace(faculties); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update managed faculties",
+		})
+	}
+
+	var names []string
+	for _, f := range faculties {
+		names = append(names, f.Name)
+	}
+	models.RecordAudit(models.AuditUserUpdated, &actor.ID, &user.ID, c.IP(),
+		"managed faculties: "+strings.Join(names, ", "))
+
+	var responses []models.FacultyResponse
+	for _, f := range faculties {
+		responses = append(responses, f.ToResponse())
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Managed faculties updated successfully",
+		"data":    responses,
+	})
+}
+
+func uniqueIDs(ids []uint) []uint {
+	seen := map[uint]bool{}
+	var result []uint
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
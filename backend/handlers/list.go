@@ -0,0 +1,388 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"synergy_dms/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Paging, sorting and filtering shared by the list endpoints. Pages are
+// keyset-based: next_cursor holds the sort value and ID of the last row, so
+// deep pages cost as much as the first and rows added meanwhile do not shift
+// them.
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+	maxFilterLength = 200
+)
+
+// listParams are the ?limit, ?sort and ?cursor of a list request
+type listParams struct {
+	sort   string
+	desc   bool
+	table  string
+	field  *schema.Field
+	id     *schema.Field
+	limit  int
+	cursor bool
+	// Sort value (nil for NULL) and ID of the last row of the previous page
+	afterValue interface{}
+	afterID    interface{}
+}
+
+// pageCursor is the position after the last row of a page
+type pageCursor struct {
+	Sort  string          `json:"s"`
+	Value json.RawMessage `json:"v"`
+	ID    json.RawMessage `json:"id"`
+}
+
+// page describes the rows returned by fetchPage
+type page struct {
+	Total      int64
+	NextCursor *string
+}
+
+// parseListParams reads the paging options of a list over model. ?sort is
+// one of columns, prefixed with "-" for descending order. On bad input it
+// responds with 400 and returns nil.
+func parseListParams(c *fiber.Ctx, model interface{}, columns []string, defaultSort string) (*listParams, error) {
+	stmt := &gorm.Statement{DB: models.DB}
+	if err := stmt.Parse(model); err != nil {
+		return nil, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to read list parameters",
+		})
+	}
+
+	p := &listParams{
+		sort:  c.Query("sort", defaultSort),
+		table: stmt.Schema.Table,
+		id:    stmt.Schema.PrioritizedPrimaryField,
+		limit: c.QueryInt("limit", defaultPageSize),
+	}
+	if p.limit < 1 || p.limit > maxPageSize {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": fmt.Sprintf("limit must be between 1 and %d", maxPageSize),
+		})
+	}
+
+	column, desc := strings.CutPrefix(p.sort, "-")
+	p.desc = desc
+	for _, allowed := range columns {
+		if allowed == column {
+			p.field = stmt.Schema.LookUpField(column)
+		}
+	}
+	if p.field == nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "sort must be one of: " + strings.Join(columns, ", ") + " (prefix - for descending)",
+		})
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		var cursor pageCursor
+		data, err := base64.RawURLEncoding.DecodeString(raw)
+		if err == nil {
+			err = json.Unmarshal(data, &cursor)
+		}
+		if err == nil && cursor.Sort == p.sort {
+			p.afterValue, err = cursorValue(p.field, cursor.Value)
+		}
+		if err == nil {
+			p.afterID, err = cursorValue(p.id, cursor.ID)
+		}
+		if err != nil || cursor.Sort != p.sort || p.afterID == nil {
+			return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid cursor",
+			})
+		}
+		p.cursor = true
+	}
+	return p, nil
+}
+
+func (p *listParams) column(field *schema.Field) string {
+	return p.table + "." + field.DBName
+}
+
+// order sorts by the chosen column with the ID as tie-breaker. NULLs come
+// last in both directions so the cursor condition stays simple.
+func (p *listParams) order() string {
+	dir := "ASC"
+	if p.desc {
+		dir = "DESC"
+	}
+	return fmt.Sprintf("%s %s NULLS LAST, %s %s", p.column(p.field), dir, p.column(p.id), dir)
+}
+
+// after restricts the query to the rows following the cursor
+func (p *listParams) after(query *gorm.DB) *gorm.DB {
+	cmp := ">"
+	if p.desc {
+		cmp = "<"
+	}
+	col, idCol := p.column(p.field), p.column(p.id)
+	if p.afterValue == nil {
+		return query.Where(fmt.Sprintf("%s IS NULL AND %s %s ?", col, idCol, cmp), p.afterID)
+	}
+	return query.Where(fmt.Sprintf("(%[1]s %[3]s ? OR (%[1]s = ? AND %[2]s %[3]s ?) OR %[1]s IS NULL)", col, idCol, cmp),
+		p.afterValue, p.afterValue, p.afterID)
+}
+
+// cursorValue decodes a cursor value into the Go type of the field; nil
+// stands for NULL
+func cursorValue(field *schema.Field, raw json.RawMessage) (interface{}, error) {
+	v := reflect.New(field.FieldType)
+	if err := json.Unmarshal(raw, v.Interface()); err != nil {
+		return nil, err
+	}
+	v = v.Elem()
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	return v.Interface(), nil
+}
+
+// fetchPage counts the rows of query and loads one page of them into dest,
+// a pointer to a slice. preload, if set, adds the relations to load.
+func fetchPage(query *gorm.DB, p *listParams, dest interface{}, preload func(*gorm.DB) *gorm.DB) (*page, error) {
+	result := &page{}
+	if err := query.Session(&gorm.Session{}).Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+
+	find := query.Session(&gorm.Session{})
+	if p.cursor {
+		find = p.after(find)
+	}
+	if preload != nil {
+		find = preload(find)
+	}
+	// One extra row tells whether there is a next page
+	if err := find.Order(p.order()).Limit(p.limit + 1).Find(dest).Error; err != nil {
+		return nil, err
+	}
+
+	rows := reflect.ValueOf(dest).Elem()
+	if rows.Len() <= p.limit {
+		return result, nil
+	}
+	rows.Set(rows.Slice(0, p.limit))
+
+	next, err := p.nextCursor(rows.Index(p.limit - 1))
+	if err != nil {
+		return nil, err
+	}
+	result.NextCursor = &next
+	return result, nil
+}
+
+// nextCursor encodes the position after row
+func (p *listParams) nextCursor(row reflect.Value) (string, error) {
+	value, _ := p.field.ValueOf(context.Background(), row)
+	id, _ := p.id.ValueOf(context.Background(), row)
+	cursor := pageCursor{Sort: p.sort}
+	var err error
+	if cursor.Value, err = json.Marshal(value); err != nil {
+		return "", err
+	}
+	if cursor.ID, err = json.Marshal(id); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// sendPage writes the envelope shared by the list endpoints. next_cursor is
+// null on the last page.
+func sendPage(c *fiber.Ctx, data interface{}, count int, p *page) error {
+	return c.JSON(fiber.Map{
+		"success":     true,
+		"data":        data,
+		"count":       count,
+		"total":       p.Total,
+		"next_cursor": p.NextCursor,
+	})
+}
+
+// listFilter applies the optional filters of a list request. The first
+// malformed parameter is kept in bad.
+type listFilter struct {
+	c     *fiber.Ctx
+	query *gorm.DB
+	bad   string
+}
+
+func newListFilter(c *fiber.Ctx, query *gorm.DB) *listFilter {
+	return &listFilter{c: c, query: query}
+}
+
+func (f *listFilter) invalid(param string) {
+	if f.bad == "" {
+		f.bad = param
+	}
+}
+
+// equal matches column against the parameter as given
+func (f *listFilter) equal(param, column string) {
+	if value := f.c.Query(param); value != "" {
+		f.query = f.query.Where(column+" = ?", value)
+	}
+}
+
+// id matches an ID column; "none" selects rows where it is not set
+func (f *listFilter) id(param, column string) {
+	value := f.c.Query(param)
+	switch value {
+	case "":
+	case "none":
+		f.query = f.query.Where(column + " IS NULL")
+	default:
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			f.invalid(param)
+			return
+		}
+		f.query = f.query.Where(column+" = ?", id)
+	}
+}
+
+func (f *listFilter) boolean(param, column string) {
+	if value := f.c.Query(param); value != "" {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			f.invalid(param)
+			return
+		}
+		f.query = f.query.Where(column+" = ?", b)
+	}
+}
+
+// dateRange limits column to [from, to]. Dates are YYYY-MM-DD or RFC 3339;
+// a plain date as the upper bound includes the whole day.
+func (f *listFilter) dateRange(fromParam, toParam, column string) {
+	if value := f.c.Query(fromParam); value != "" {
+		from, _, err := parseFilterTime(value)
+		if err != nil {
+			f.invalid(fromParam)
+			return
+		}
+		f.query = f.query.Where(column+" >= ?", from)
+	}
+	if value := f.c.Query(toParam); value != "" {
+		to, dateOnly, err := parseFilterTime(value)
+		if err != nil {
+			f.invalid(toParam)
+			return
+		}
+		if dateOnly {
+			f.query = f.query.Where(column+" < ?", to.AddDate(0, 0, 1))
+		} else {
+			f.query = f.query.Where(column+" <= ?", to)
+		}
+	}
+}
+
+func parseFilterTime(value string) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
+
+// contains matches rows where any of the columns contains the parameter,
+// ignoring case
+func (f *listFilter) contains(param string, columns ...string) {
+	value := strings.TrimSpace(f.c.Query(param))
+	if value == "" {
+		return
+	}
+	if len([]rune(value)) > maxFilterLength {
+		f.invalid(param)
+		return
+	}
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value) + "%"
+	conditions := make([]string, len(columns))
+	args := make([]interface{}, len(columns))
+	for i, column := range columns {
+		conditions[i] = column + " ILIKE ?"
+		args[i] = pattern
+	}
+	f.query = f.query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+}
+
+// jsonFields filters by the keys of a JSON object column: ?<prefix>name=value
+// matches the value, ?<prefix>name.from= and ?<prefix>name.to= bound it.
+// Bounds that are numbers compare numerically, others as text, which suits
+// ISO dates.
+func (f *listFilter) jsonFields(prefix, column string) {
+	params := f.c.Queries()
+	names := make([]string, 0, len(params))
+	for param := range params {
+		if strings.HasPrefix(param, prefix) {
+			names = append(names, param)
+		}
+	}
+	// Stable SQL for the same request
+	sort.Strings(names)
+
+	for _, param := range names {
+		value := params[param]
+		name, op := strings.TrimPrefix(param, prefix), "="
+		if key, ok := strings.CutSuffix(name, ".from"); ok {
+			name, op = key, ">="
+		} else if key, ok := strings.CutSuffix(name, ".to"); ok {
+			name, op = key, "<="
+		}
+		if !jsonFieldName.MatchString(name) || len([]rune(value)) > maxFilterLength {
+			f.invalid(param)
+			return
+		}
+
+		if op == "=" {
+			f.query = f.query.Where(column+" ->> ? = ?", name, value)
+			continue
+		}
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			f.query = f.query.Where("CASE WHEN jsonb_typeof("+column+" -> ?) = 'number' "+
+				"THEN ("+column+" ->> ?)::numeric END "+op+" ?", name, name, number)
+		} else {
+			f.query = f.query.Where(column+" ->> ? "+op+" ?", name, value)
+		}
+	}
+}
+
+var jsonFieldName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// respond reports the first invalid filter with 400
+func (f *listFilter) respond() error {
+	return f.c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"success": false,
+		"message": "Invalid " + f.bad,
+	})
+}
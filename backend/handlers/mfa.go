@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"synergy_dms/middleware"
+	"synergy_dms/models"
+	"synergy_dms/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	mfaIssuer         = "Synergy DMS"
+	recoveryCodeCount = 10
+)
+
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+type MFADisableRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type MFAVerifyRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// EnrollMFA generates a new TOTP secret and returns its provisioning URI.
+// MFA stays disabled until the first code is confirmed.
+func (h *AuthHandler) EnrollMFA(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	if user.MFAEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Two-factor authentication is already enabled",
+		})
+	}
+
+	secret, err := services.GenerateTOTPSecret()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate secret",
+		})
+	}
+
+	if err := models.DB.Model(user).Update("mfa_secret", secret).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to start enrollment",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Scan the QR code with an authenticator app and confirm with a code",
+		"data": fiber.Map{
+			"secret":           secret,
+			"provisioning_uri": services.TOTPProvisioningURI(secret, user.Email, mfaIssuer),
+		},
+	})
+}
+
+// ConfirmMFA enables MFA after the user proves the authenticator works,
+// and returns a fresh set of recovery codes
+func (h *AuthHandler) ConfirmMFA(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	var req MFACodeRequest
+	if err := c.BodyParser(&req); err != nil || req.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Code is required",
+		})
+	}
+
+	if user.MFAEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Two-factor authentication is already enabled",
+		})
+	}
+
+	if user.MFASecret == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Start enrollment first",
+		})
+	}
+
+	if !verifyTOTP(user, req.Code) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid code",
+		})
+	}
+
+	codes, err := replaceRecoveryCodes(user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate recovery codes",
+		})
+	}
+
+	if err := models.DB.Model(user).Update("mfa_enabled", true).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to enable two-factor authentication",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Two-factor authentication enabled. Store the recovery codes in a safe place",
+		"data": fiber.Map{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// RegenerateRecoveryCodes invalidates the old recovery codes and issues new ones
+func (h *AuthHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	var req MFACodeRequest
+	if err := c.BodyParser(&req); err != nil || req.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Code is required",
+		})
+	}
+
+	if !user.MFAEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Two-factor authentication is not enabled",
+		})
+	}
+
+	if !verifyTOTP(user, req.Code) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid code",
+		})
+	}
+
+	codes, err := replaceRecoveryCodes(user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate recovery codes",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// DisableMFA turns two-factor authentication off unless the user's role requires it
+func (h *AuthHandler) DisableMFA(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	var req MFADisableRequest
+	if err := c.BodyParser(&req); err != nil || req.Password == "" || req.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Password and code are required",
+		})
+	}
+
+	if !user.MFAEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Two-factor authentication is not enabled",
+		})
+	}
+
+	if models.MFARequiredForRole(user.Role) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Two-factor authentication is required for your role",
+		})
+	}
+
+	if !user.CheckPassword(req.Password) || !verifyTOTP(user, req.Code) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid password or code",
+		})
+	}
+
+	if err := models.DB.Model(user).Updates(map[string]interface{}{
+		"mfa_enabled": false,
+		"mfa_secret":  "",
+	}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to disable two-factor authentication",
+		})
+	}
+	models.DB.Where("user_id = ?", user.ID).Delete(&models.MFARecoveryCode{})
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// VerifyMFA completes a two-step login with a TOTP or recovery code
+func (h *AuthHandler) VerifyMFA(c *fiber.Ctx) error {
+	var req MFAVerifyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	if req.MFAToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "MFA token and code are required",
+		})
+	}
+
+	userID, err := middleware.ParseMFAToken(req.MFAToken)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid or expired MFA token",
+		})
+	}
+
+	var user models.User
+	if err := models.DB.Preload("Faculty").First(&user, userID).Error; err != nil || !user.MFAEnabled {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid or expired MFA token",
+		})
+	}
+
+	if wait := h.LoginGuard.Check(user.Email, c.IP()); wait > 0 {
+		return tooManyAttempts(c, wait)
+	}
+
+	var ok bool
+	if req.Code != "" {
+		ok = verifyTOTP(&user, req.Code)
+	} else {
+		ok = useRecoveryCode(user.ID, req.RecoveryCode)
+	}
+	if !ok {
+		h.LoginGuard.RecordFailure(user.Email, c.IP(), &user.ID)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid code",
+		})
+	}
+	h.LoginGuard.RecordSuccess(user.Email)
+
+	tokens, err := h.startSession(c, &user)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate token",
+		})
+	}
+	tokens["user"] = user.ToResponse()
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Login successful",
+		"data":    tokens,
+	})
+}
+
+// verifyTOTP checks a code and records its time step so it cannot be replayed
+func verifyTOTP(user *models.User, code string) bool {
+	step, ok := services.ValidateTOTP(user.MFASecret, code, time.Now())
+	if !ok || step <= user.MFALastUsedStep {
+		return false
+	}
+
+	result := models.DB.Model(&models.User{}).
+		Where("id = ? AND mfa_last_used_step < ?", user.ID, step).
+		Update("mfa_last_used_step", step)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false
+	}
+	user.MFALastUsedStep = step
+	return true
+}
+
+// useRecoveryCode consumes a matching unused recovery code
+func useRecoveryCode(userID uint, code string) bool {
+	hash := models.HashToken(normalizeRecoveryCode(code))
+
+	result := models.DB.Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	return result.Error == nil && result.RowsAffected > 0
+}
+
+// replaceRecoveryCodes deletes existing recovery codes and stores hashes of new ones
+func replaceRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.MFARecoveryCode, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := models.GenerateSecureToken(5)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		records = append(records, models.MFARecoveryCode{
+			UserID:   userID,
+			CodeHash: models.HashToken(raw),
+		})
+	}
+
+	if err := models.DB.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	if err := models.DB.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
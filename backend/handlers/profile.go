@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
+
+	"synergy_dms/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const maxNameLength = 255
+
+type UpdateProfileRequest struct {
+	FullName  *string `json:"full_name"`
+	FacultyID *uint   `json:"faculty_id"`
+}
+
+type EmailChangeRequest struct {
+	NewEmail string `json:"new_email"`
+	Password string `json:"password"`
+}
+
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token"`
+}
+
+// normalizeEmail trims an address and checks that it is a bare, valid email
+func normalizeEmail(email string) (string, bool) {
+	email = strings.TrimSpace(email)
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || len(email) > maxNameLength {
+		return "", false
+	}
+	return email, true
+}
+
+// sameID compares two optional IDs
+func sameID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func facultyName(f *models.Faculty) string {
+	if f == nil {
+		return ""
+	}
+	return f.Name
+}
+
+// UpdateProfile lets the logged-in user edit their own name and faculty
+func (h *AuthHandler) UpdateProfile(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	var req UpdateProfileRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	var changes []string
+	if req.FullName != nil {
+		fullName := strings.TrimSpace(*req.FullName)
+		if fullName == "" || len(fullName) > maxNameLength {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": fmt.Sprintf("Full name must be 1-%d characters", maxNameLength),
+			})
+		}
+		if fullName != user.FullName {
+			changes = append(changes, fmt.Sprintf("full_name: %q -> %q", user.FullName, fullName))
+			user.FullName = fullName
+		}
+	}
+
+	if req.FacultyID != nil && !sameID(req.FacultyID, user.FacultyID) {
+		faculty, err := resolveFaculty(req.FacultyID, "")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Unknown faculty",
+			})
+		}
+		changes = append(changes, fmt.Sprintf("faculty: %q -> %q", facultyName(user.Faculty), facultyName(faculty)))
+		user.Faculty = faculty
+		user.FacultyID = nil
+		if faculty != nil {
+			user.FacultyID = &faculty.ID
+		}
+	}
+
+	if len(changes) > 0 {
+		if err := models.DB.Model(user).Select("full_name", "faculty_id").Updates(user).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to update profile",
+			})
+		}
+		models.RecordAudit(models.AuditProfileUpdated, &user.ID, &user.ID, c.IP(), strings.Join(changes, "; "))
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Profile updated successfully",
+		"data":    user.ToResponse(),
+	})
+}
+
+// RequestEmailChange sends a confirmation link to the new address.
+// The email is only changed once that link is used.
+func (h *AuthHandler) RequestEmailChange(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	var req EmailChangeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	newEmail, ok := normalizeEmail(req.NewEmail)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "A valid new email is required",
+		})
+	}
+
+	if !user.CheckPassword(req.Password) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "Password is incorrect",
+		})
+	}
+
+	if strings.EqualFold(newEmail, user.Email) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "New email is the same as the current one",
+		})
+	}
+
+	var count int64
+	models.DB.Unscoped().Model(&models.User{}).Where("LOWER(email) = LOWER(?)", newEmail).Count(&count)
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "Email already registered",
+		})
+	}
+
+	token, err := models.GenerateSecureToken(32)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate confirmation token",
+		})
+	}
+
+	// Only the most recent request stays valid
+	now := time.Now()
+	models.DB.Where("user_id = ? AND confirmed_at IS NULL", user.ID).Delete(&models.EmailChangeRequest{})
+
+	changeRequest := models.EmailChangeRequest{
+		UserID:    user.ID,
+		NewEmail:  newEmail,
+		TokenHash: models.HashToken(token),
+		ExpiresAt: now.Add(h.Config.EmailChangeTTL),
+	}
+	if err := models.DB.Create(&changeRequest).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to create email change request",
+		})
+	}
+
+	body := fmt.Sprintf("Hello, %s!\n\nTo confirm %s as your new Synergy DMS email, open the link below:\n%s/confirm-email?token=%s\n\nThe link expires in %s.",
+		user.FullName, newEmail, h.Config.AppBaseURL, token, h.Config.EmailChangeTTL)
+	if err := h.Mailer.Send(newEmail, "Confirm your new Synergy DMS email", body); err != nil {
+		log.Printf("❌ Failed to send email change confirmation: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to send confirmation email",
+		})
+	}
+
+	models.RecordAudit(models.AuditEmailRequested, &user.ID, &user.ID, c.IP(),
+		fmt.Sprintf("Requested change from %s to %s", user.Email, newEmail))
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Confirmation link sent to the new email address",
+	})
+}
+
+// ConfirmEmailChange applies a pending email change using the emailed token
+func (h *AuthHandler) ConfirmEmailChange(c *fiber.Ctx) error {
+	var req ConfirmEmailChangeRequest
+	if err := c.BodyParser(&req); err != nil || req.Token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Token is required",
+		})
+	}
+
+	var changeRequest models.EmailChangeRequest
+	if err := models.DB.Preload("User").Where("token_hash = ?", models.HashToken(req.Token)).
+		First(&changeRequest).Error; err != nil || !changeRequest.IsUsable() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid or expired confirmation token",
+		})
+	}
+
+	var count int64
+	models.DB.Unscoped().Model(&models.User{}).Where("LOWER(email) = LOWER(?)", changeRequest.NewEmail).Count(&count)
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "Email already registered",
+		})
+	}
+
+	user := changeRequest.User
+	oldEmail := user.Email
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.EmailChangeRequest{}).
+			Where("id = ? AND confirmed_at IS NULL", changeRequest.ID).
+			Update("confirmed_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("email change request already confirmed")
+		}
+		return tx.Model(&user).Update("email", changeRequest.NewEmail).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to change email",
+		})
+	}
+	user.Email = changeRequest.NewEmail
+
+	models.RecordAudit(models.AuditEmailChanged, &user.ID, &user.ID, c.IP(),
+		fmt.Sprintf("email: %q -> %q", oldEmail, user.Email))
+
+	notice := fmt.Sprintf("Hello, %s!\n\nThe email of your Synergy DMS account was changed to %s. If you did not do this, contact the administration.",
+		user.FullName, user.Email)
+	if err := h.Mailer.Send(oldEmail, "Your Synergy DMS email was changed", notice); err != nil {
+		log.Printf("❌ Failed to notify old email address: %v", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Email changed successfully",
+		"data":    user.ToResponse(),
+	})
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"html"
+	"log"
+	"strings"
+
+	"synergy_dms/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Search result paging
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	maxSearchQuery     = 200
+)
+
+// ts_headline marks matches with private-use characters so the text can be
+// escaped before the marks become HTML
+const (
+	highlightStart = "\uE000"
+	highlightStop  = "\uE001"
+
+	titleHighlightOptions   = "HighlightAll=true, StartSel=" + highlightStart + ", StopSel=" + highlightStop
+	snippetHighlightOptions = "MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter=\" … \", " +
+		"StartSel=" + highlightStart + ", StopSel=" + highlightStop
+)
+
+// refreshSearchIndex updates the search vectors of a document after a change
+// made outside a transaction. The document stays findable by its old text
+// if this fails.
+func refreshSearchIndex(documentID uint) {
+	if err := models.RefreshSearchIndex(models.DB, documentID); err != nil {
+		log.Printf("⚠️  Failed to refresh search index of document %d: %v", documentID, err)
+	}
+}
+
+// highlight escapes a ts_headline result and turns its marks into <mark>
+func highlight(text string) string {
+	text = html.EscapeString(text)
+	text = strings.ReplaceAll(text, highlightStart, "<mark>")
+	return strings.ReplaceAll(text, highlightStop, "</mark>")
+}
+
+// SearchDocuments runs a full-text search over the title, description,
+// comments and file text of the documents the user may see. Results are
+// ranked; ?limit and ?offset page through them.
+func (h *DocumentHandler) SearchDocuments(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" || len([]rune(q)) > maxSearchQuery {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Search query must be between 1 and 200 characters",
+		})
+	}
+
+	limit := c.QueryInt("limit", defaultSearchLimit)
+	if limit < 1 || limit > maxSearchLimit {
+		limit = defaultSearchLimit
+	}
+	offset := c.QueryInt("offset", 0)
+	if offset < 0 {
+		offset = 0
+	}
+
+	// Internal comments are only searched for admins
+	vector := "document_search.vector"
+	if isAdminRole(user.Role) {
+		vector = "(document_search.vector || document_search.internal_vector)"
+	}
+
+	query := visibleDocuments(models.DB.Model(&models.Document{}), user).
+		Joins("JOIN document_search ON document_search.document_id = documents.id").
+		Where(vector+" @@ "+models.SearchQuery, q, q).
+		Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to search documents",
+		})
+	}
+
+	var hits []struct {
+		ID   uint
+		Rank float32 `gorm:"column:search_rank"`
+	}
+	if err := query.Select("documents.id, ts_rank("+vector+", "+models.SearchQuery+") AS search_rank", q, q).
+		Order("search_rank DESC, documents.id DESC").Limit(limit).Offset(offset).
+		Scan(&hits).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to search documents",
+		})
+	}
+
+	results := []models.DocumentSearchResult{}
+	if len(hits) > 0 {
+		ids := make([]uint, len(hits))
+		for i, hit := range hits {
+			ids[i] = hit.ID
+		}
+
+		// Snippets come from the texts the user may see
+		snippetText := "concat_ws(' ', documents.description, search_texts.comments, search_texts.files)"
+		if isAdminRole(user.Role) {
+			snippetText = "concat_ws(' ', documents.description, search_texts.comments, " +
+				"search_texts.internal_comments, search_texts.files)"
+		}
+		var highlights []struct {
+			ID      uint
+			Title   string
+			Snippet string
+		}
+		if err := models.DB.Table("documents").
+			Select("documents.id, "+
+				"ts_headline('russian', documents.title, "+models.SearchQuery+", ?) AS title, "+
+				"ts_headline('russian', "+snippetText+", "+models.SearchQuery+", ?) AS snippet",
+				q, q, titleHighlightOptions, q, q, snippetHighlightOptions).
+			Joins("CROSS JOIN "+models.SearchTexts).
+			Where("documents.id IN ?", ids).
+			Scan(&highlights).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to search documents",
+			})
+		}
+
+		var documents []models.Document
+		if err := models.PreloadDocumentRelations(models.DB).Where("id IN ?", ids).
+			Find(&documents).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to search documents",
+			})
+		}
+
+		byID := make(map[uint]*models.Document, len(documents))
+		for i := range documents {
+			byID[documents[i].ID] = &documents[i]
+		}
+		titles := make(map[uint]string, len(highlights))
+		snippets := make(map[uint]string, len(highlights))
+		for _, hl := range highlights {
+			titles[hl.ID] = highlight(hl.Title)
+			// Without a match in the text ts_headline returns its beginning
+			if strings.Contains(hl.Snippet, highlightStart) {
+				snippets[hl.ID] = highlight(hl.Snippet)
+			}
+		}
+
+		for _, hit := range hits {
+			document, ok := byID[hit.ID]
+			if !ok {
+				continue
+			}
+			results = append(results, models.DocumentSearchResult{
+				DocumentResponse: document.ToResponse(),
+				Rank:             hit.Rank,
+				TitleHighlight:   titles[hit.ID],
+				Snippet:          snippets[hit.ID],
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    results,
+		"count":   len(results),
+		"total":   total,
+	})
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"strings"
+
+	"synergy_dms/models"
+	"synergy_dms/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type SettingsHandler struct{}
+
+func NewSettingsHandler() *SettingsHandler {
+	return &SettingsHandler{}
+}
+
+type MFAPolicyRequest struct {
+	RequiredRoles []models.UserRole `json:"required_roles"`
+}
+
+// GetMFAPolicy returns the roles that must use two-factor authentication (Super-Admin only)
+func (h *SettingsHandler) GetMFAPolicy(c *fiber.Ctx) error {
+	roles := models.MFARequiredRoles()
+	if roles == nil {
+		roles = []models.UserRole{}
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"required_roles": roles,
+		},
+	})
+}
+
+// UpdateMFAPolicy sets the roles that must use two-factor authentication (Super-Admin only)
+func (h *SettingsHandler) UpdateMFAPolicy(c *fiber.Ctx) error {
+	var req MFAPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	roles := []models.UserRole{}
+	seen := map[models.UserRole]bool{}
+	for _, role := range req.RequiredRoles {
+		if role != models.RoleAdmin && role != models.RoleSuperAdmin {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "MFA can only be required for 'admin' and 'super_admin' roles",
+			})
+		}
+		if !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+
+	if err := models.SetSettingJSON(models.SettingMFARequiredRoles, roles); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update MFA policy",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "MFA policy updated",
+		"data": fiber.Map{
+			"required_roles": roles,
+		},
+	})
+}
+
+type UploadTypesRequest struct {
+	AllowedExtensions []string `json:"allowed_extensions"`
+}
+
+// uploadTypesResponse lists the allowed extensions together with every type
+// the server can recognise
+func uploadTypesResponse(allowed []string) fiber.Map {
+	return fiber.Map{
+		"allowed_extensions": allowed,
+		"available_types":    services.KnownFileTypes,
+	}
+}
+
+// GetUploadTypes returns the file types users may upload (Super-Admin only)
+func (h *SettingsHandler) GetUploadTypes(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    uploadTypesResponse(models.AllowedUploadExtensions()),
+	})
+}
+
+// UpdateUploadTypes sets the file types users may upload (Super-Admin only).
+// Only types whose content the server can verify are accepted.
+func (h *SettingsHandler) UpdateUploadTypes(c *fiber.Ctx) error {
+	var req UploadTypesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	extensions := []string{}
+	seen := map[string]bool{}
+	for _, ext := range req.AllowedExtensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		if _, known := services.LookupFileType(ext); !known {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Unsupported file type: " + ext,
+			})
+		}
+		if !seen[ext] {
+			seen[ext] = true
+			extensions = append(extensions, ext)
+		}
+	}
+
+	if err := models.SetSettingJSON(models.SettingUploadTypes, extensions); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update upload types",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Upload types updated",
+		"data":    uploadTypesResponse(extensions),
+	})
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"synergy_dms/models"
+	"synergy_dms/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type StorageHandler struct {
+	Verifier *services.IntegrityVerifier
+	Orphans  *services.OrphanCleanupService
+	Scans    *services.ScanService
+}
+
+func NewStorageHandler(verifier *services.IntegrityVerifier, orphans *services.OrphanCleanupService,
+	scans *services.ScanService) *StorageHandler {
+	return &StorageHandler{Verifier: verifier, Orphans: orphans, Scans: scans}
+}
+
+// GetIntegrityReport returns the last verification run and the blobs that
+// are missing or corrupted (Super-Admin only)
+func (h *StorageHandler) GetIntegrityReport(c *fiber.Ctx) error {
+	var damaged []models.Blob
+	if err := models.DB.Where("status <> ?", models.BlobOK).Order("updated_at DESC").
+		Find(&damaged).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch integrity report",
+		})
+	}
+
+	var stats struct {
+		Blobs int64
+		Bytes int64
+		Refs  int64
+	}
+	models.DB.Model(&models.Blob{}).
+		Select("COUNT(*) AS blobs, COALESCE(SUM(size), 0) AS bytes, COALESCE(SUM(ref_count), 0) AS refs").
+		Scan(&stats)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"running":    h.Verifier.Running(),
+			"last_run":   h.Verifier.LastReport(),
+			"blobs":      stats.Blobs,
+			"bytes":      stats.Bytes,
+			"references": stats.Refs,
+			"damaged":    damaged,
+		},
+	})
+}
+
+// VerifyIntegrity starts a verification run in the background (Super-Admin only)
+func (h *StorageHandler) VerifyIntegrity(c *fiber.Ctx) error {
+	if h.Verifier.Running() {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "Integrity check is already running",
+		})
+	}
+
+	go h.Verifier.Run()
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"success": true,
+		"message": "Integrity check started",
+	})
+}
+
+// GetOrphans lists the files the next cleanup would delete, without deleting
+// anything, together with the cleanup counters (Super-Admin only)
+func (h *StorageHandler) GetOrphans(c *fiber.Ctx) error {
+	report, err := h.Orphans.Collect(true)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to find orphaned files",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"grace_period": h.Orphans.GracePeriod.String(),
+			"report":       report,
+			"metrics":      h.Orphans.Metrics(),
+		},
+	})
+}
+
+// GetScanErrors lists the uploads the antivirus scanner gave up on. They stay
+// quarantined until rescanned (Super-Admin only).
+func (h *StorageHandler) GetScanErrors(c *fiber.Ctx) error {
+	var uploads []models.Upload
+	if err := models.DB.Where("scan_status = ?", models.ScanError).Order("scanned_at DESC").
+		Find(&uploads).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch scan errors",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    uploads,
+		"count":   len(uploads),
+	})
+}
+
+// RescanUpload queues an upload the scanner gave up on for another scan,
+// e.g. after clamd's size limits were raised (Super-Admin only)
+func (h *StorageHandler) RescanUpload(c *fiber.Ctx) error {
+	if !h.Scans.Enabled() {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "Antivirus scanning is disabled",
+		})
+	}
+
+	var upload models.Upload
+	if err := models.DB.Where("stored_name = ?", c.Params("filename")).First(&upload).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Upload not found",
+		})
+	}
+	if upload.ScanStatus != models.ScanError {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "Upload is not waiting for a rescan",
+		})
+	}
+
+	if err := h.Scans.Rescan(&upload); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to queue the rescan",
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"success": true,
+		"message": "Rescan queued",
+	})
+}
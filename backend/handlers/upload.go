@@ -1,70 +1,95 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
+	"io"
+	"mime/multipart"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"synergy_dms/models"
+	"synergy_dms/services"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
 
+// Max upload size (50MB)
+const maxUploadSize = int64(50 * 1024 * 1024)
+
+var (
+	errFileTooLarge   = errors.New("file too large")
+	errFileNotAllowed = errors.New("file type not allowed")
+)
+
 type UploadHandler struct {
-	UploadDir string
+	Blobs     *services.Blobs
+	Scans     *services.ScanService
+	Resumable *services.ResumableUploads
+	Previews  *services.PreviewService
+	Indexer   *services.TextIndexer
 }
 
-func NewUploadHandler(uploadDir string) *UploadHandler {
-	// Ensure upload directory exists
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		panic(fmt.Sprintf("Failed to create upload directory: %v", err))
-	}
-	return &UploadHandler{UploadDir: uploadDir}
+func NewUploadHandler(blobs *services.Blobs, scans *services.ScanService, resumable *services.ResumableUploads,
+	previews *services.PreviewService, indexer *services.TextIndexer) *UploadHandler {
+	return &UploadHandler{Blobs: blobs, Scans: scans, Resumable: resumable, Previews: previews, Indexer: indexer}
 }
 
-func (h *UploadHandler) UploadFile(c *fiber.Ctx) error {
-	file, err := c.FormFile("file")
+// uploadSource is the content of a received file
+type uploadSource interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+}
+
+// storeUpload validates a multipart file, stores its content and records
+// it in quarantine under a unique name. The caller enqueues the antivirus scan once
+// everything referring to the file is saved.
+func storeUpload(c *fiber.Ctx, file *multipart.FileHeader, blobs *services.Blobs, scans *services.ScanService,
+	uploaderID uint) (*models.Upload, error) {
+	// Validate file size
+	if file.Size > maxUploadSize {
+		return nil, errFileTooLarge
+	}
+
+	src, err := file.Open()
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"success": false,
-			"message": "No file uploaded",
-		})
+		return nil, err
 	}
+	defer src.Close()
 
-	// Validate file size (max 50MB)
-	maxSize := int64(50 * 1024 * 1024)
-	if file.Size > maxSize {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"success": false,
-			"message": "File size exceeds 50MB limit",
-		})
+	return saveUpload(c.UserContext(), src, file.Size, file.Filename, blobs, scans, uploaderID)
+}
+
+// saveUpload checks the type of a received file and stores it. Both the
+// multipart and the resumable upload end here.
+func saveUpload(ctx context.Context, src uploadSource, size int64, filename string, blobs *services.Blobs,
+	scans *services.ScanService, uploaderID uint) (*models.Upload, error) {
+	// Validate file extension against the runtime allow-list
+	ext := strings.ToLower(filepath.Ext(filename))
+	if !models.UploadExtensionAllowed(ext) {
+		return nil, errFileNotAllowed
 	}
 
-	// Validate file extension
-	allowedExtensions := map[string]bool{
-		".pdf":  true,
-		".doc":  true,
-		".docx": true,
-		".xls":  true,
-		".xlsx": true,
-		".ppt":  true,
-		".pptx": true,
-		".txt":  true,
-		".png":  true,
-		".jpg":  true,
-		".jpeg": true,
-		".gif":  true,
-		".zip":  true,
-		".rar":  true,
+	// The content must really be what the extension says
+	mimeType, err := services.DetectFileType(src, size, ext)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, err
 	}
 
-	ext := strings.ToLower(filepath.Ext(file.Filename))
-	if !allowedExtensions[ext] {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"success": false,
-			"message": "File type not allowed",
-		})
+	// Identical content is stored once
+	checksum, _, err := services.HashContent(src)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, err
 	}
 
 	// Generate unique filename
@@ -72,26 +97,99 @@ func (h *UploadHandler) UploadFile(c *fiber.Ctx) error {
 	timestamp := time.Now().Format("20060102_150405")
 	newFilename := fmt.Sprintf("%s_%s%s", timestamp, uniqueID[:8], ext)
 
+	upload := models.Upload{
+		StoredName:   newFilename,
+		OriginalName: filepath.Base(filename),
+		MimeType:     mimeType,
+		Size:         size,
+		Checksum:     checksum,
+		UploaderID:   uploaderID,
+	}
+	scans.SetInitialStatus(&upload)
+
 	// Save file
-	filePath := filepath.Join(h.UploadDir, newFilename)
-	if err := c.SaveFile(file, filePath); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+	if err := blobs.Store(ctx, src, &upload); err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// isRejectedUpload reports whether the file itself failed validation
+func isRejectedUpload(err error) bool {
+	return errors.Is(err, errFileTooLarge) || errors.Is(err, errFileNotAllowed) ||
+		errors.Is(err, services.ErrContentMismatch) || errors.Is(err, services.ErrMalformedDocument)
+}
+
+// uploadError turns a storeUpload error into a response
+func uploadError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errFileTooLarge):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "File size exceeds 50MB limit",
+		})
+	case errors.Is(err, errFileNotAllowed):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "File type not allowed",
+		})
+	case errors.Is(err, services.ErrContentMismatch):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
-			"message": "Failed to save file",
+			"message": "File content does not match its extension",
+		})
+	case errors.Is(err, services.ErrMalformedDocument):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "File is damaged or not a valid document",
 		})
 	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"success": false,
+		"message": "Failed to save file",
+	})
+}
 
-	// Return URL path
-	fileURL := "/uploads/" + newFilename
+func (h *UploadHandler) UploadFile(c *fiber.Ctx) error {
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "No file uploaded",
+		})
+	}
+
+	user := c.Locals("user").(*models.User)
+	upload, err := storeUpload(c, file, h.Blobs, h.Scans, user.ID)
+	if err != nil {
+		return uploadError(c, err)
+	}
+	h.Scans.Enqueue(upload.ID)
+	h.Previews.Enqueue(upload.Checksum)
+	h.Indexer.Enqueue(upload.Checksum)
 
 	return c.JSON(fiber.Map{
 		"success": true,
 		"message": "File uploaded successfully",
-		"data": fiber.Map{
-			"filename":      newFilename,
-			"original_name": file.Filename,
-			"size":          file.Size,
-			"url":           fileURL,
-		},
+		"data":    upload.ToResponse(),
+	})
+}
+
+// GetUploadStatus returns the scan verdict of a file the user uploaded
+func (h *UploadHandler) GetUploadStatus(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	var upload models.Upload
+	if err := models.DB.Where("stored_name = ? AND uploader_id = ?", c.Params("filename"), user.ID).
+		First(&upload).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Upload not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    upload.ToResponse(),
 	})
 }
@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"synergy_dms/models"
+	"synergy_dms/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Resumable uploads follow the tus 1.0.0 protocol (https://tus.io) with the
+// creation, expiration and termination extensions
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,expiration,termination"
+	tusChunkType  = "application/offset+octet-stream"
+)
+
+// parseTusMetadata decodes the Upload-Metadata header: comma-separated
+// "key base64value" pairs
+func parseTusMetadata(header string) map[string]string {
+	metadata := map[string]string{}
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.Fields(pair)
+		if len(parts) == 0 {
+			continue
+		}
+		value := ""
+		if len(parts) > 1 {
+			decoded, err := base64.StdEncoding.DecodeString(parts[1])
+			if err != nil {
+				continue
+			}
+			value = string(decoded)
+		}
+		metadata[parts[0]] = value
+	}
+	return metadata
+}
+
+// setTusOffset sends the upload state headers
+func setTusOffset(c *fiber.Ctx, upload *models.ResumableUpload) {
+	c.Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	c.Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Set(fiber.HeaderCacheControl, "no-store")
+}
+
+// findResumableUpload loads the :id upload of the current user
+func (h *UploadHandler) findResumableUpload(c *fiber.Ctx) (*models.ResumableUpload, error) {
+	user := c.Locals("user").(*models.User)
+
+	upload, err := h.Resumable.Find(c.Params("id"), user.ID)
+	if err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Upload not found",
+		})
+	}
+	return upload, nil
+}
+
+// TusProtocol checks the protocol version of resumable upload requests
+func (h *UploadHandler) TusProtocol(c *fiber.Ctx) error {
+	c.Set("Tus-Resumable", tusVersion)
+
+	if c.Method() != fiber.MethodOptions && c.Get("Tus-Resumable") != tusVersion {
+		c.Set("Tus-Version", tusVersion)
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{
+			"success": false,
+			"message": "Unsupported tus protocol version",
+		})
+	}
+	return c.Next()
+}
+
+// TusOptions describes the server capabilities
+func (h *UploadHandler) TusOptions(c *fiber.Ctx) error {
+	c.Set("Tus-Version", tusVersion)
+	c.Set("Tus-Extension", tusExtensions)
+	c.Set("Tus-Max-Size", strconv.FormatInt(h.Resumable.MaxSize, 10))
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// CreateResumableUpload starts an upload. The client sends Upload-Length and
+// the file name in Upload-Metadata, then PATCHes the data to Location.
+func (h *UploadHandler) CreateResumableUpload(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	length, err := strconv.ParseInt(c.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Upload-Length header is required",
+		})
+	}
+	if length > h.Resumable.MaxSize {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"success": false,
+			"message": fmt.Sprintf("File size exceeds %dMB limit", h.Resumable.MaxSize/(1024*1024)),
+		})
+	}
+
+	metadata := parseTusMetadata(c.Get("Upload-Metadata"))
+	filename := metadata["filename"]
+	if filename == "" {
+		filename = metadata["name"]
+	}
+	filename = filepath.Base(strings.TrimSpace(filename))
+	if filename == "" || filename == "." {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "File name is required in Upload-Metadata",
+		})
+	}
+
+	// Reject unwanted types before any data is sent
+	if !models.UploadExtensionAllowed(strings.ToLower(filepath.Ext(filename))) {
+		return uploadError(c, errFileNotAllowed)
+	}
+
+	upload, err := h.Resumable.Create(user.ID, filename, length)
+	if err != nil {
+		log.Printf("❌ Failed to create resumable upload: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to create upload",
+		})
+	}
+
+	c.Set(fiber.HeaderLocation, "/api/uploads/"+upload.ID)
+	c.Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	return c.SendStatus(fiber.StatusCreated)
+}
+
+// GetResumableUploadOffset reports how many bytes were received (HEAD)
+func (h *UploadHandler) GetResumableUploadOffset(c *fiber.Ctx) error {
+	upload, err := h.findResumableUpload(c)
+	if upload == nil {
+		return err
+	}
+
+	setTusOffset(c, upload)
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// GetResumableUpload returns the upload state and, once finished, the
+// stored file to use as file_path
+func (h *UploadHandler) GetResumableUpload(c *fiber.Ctx) error {
+	upload, err := h.findResumableUpload(c)
+	if upload == nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    upload.ToResponse(),
+	})
+}
+
+// PatchResumableUpload appends a chunk at Upload-Offset. The last chunk
+// runs the same checks as UploadFile and stores the file.
+func (h *UploadHandler) PatchResumableUpload(c *fiber.Ctx) error {
+	unlock, ok := h.Resumable.Lock(c.Params("id"))
+	if !ok {
+		return c.Status(fiber.StatusLocked).JSON(fiber.Map{
+			"success": false,
+			"message": "Upload is already being written",
+		})
+	}
+	defer unlock()
+
+	upload, err := h.findResumableUpload(c)
+	if upload == nil {
+		return err
+	}
+
+	if c.Get(fiber.HeaderContentType) != tusChunkType {
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{
+			"success": false,
+			"message": "Content-Type must be " + tusChunkType,
+		})
+	}
+
+	offset, err := strconv.ParseInt(c.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Upload-Offset header is required",
+		})
+	}
+
+	// Already finished: repeating the last chunk is harmless
+	if upload.UploadID != nil {
+		setTusOffset(c, upload)
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+
+	// Large chunks are streamed: the bytes that arrive before a dropped
+	// connection are kept
+	var chunk io.Reader = c.Context().RequestBodyStream()
+	if chunk == nil {
+		chunk = bytes.NewReader(c.Body())
+	}
+	err = h.Resumable.Append(upload, offset, chunk)
+	if errors.Is(err, services.ErrOffsetMismatch) {
+		setTusOffset(c, upload)
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "Upload-Offset does not match the received data",
+		})
+	}
+	if err != nil {
+		log.Printf("❌ Failed to write resumable upload %s: %v", upload.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to save chunk",
+		})
+	}
+
+	if upload.IsComplete() {
+		if err := h.completeResumableUpload(c, upload); err != nil {
+			// Rejected files are discarded; after other failures the
+			// client can repeat the last PATCH
+			if isRejectedUpload(err) {
+				h.Resumable.Remove(upload)
+			} else {
+				log.Printf("❌ Failed to store resumable upload %s: %v", upload.ID, err)
+			}
+			return uploadError(c, err)
+		}
+	}
+
+	setTusOffset(c, upload)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// completeResumableUpload validates and stores the received file
+func (h *UploadHandler) completeResumableUpload(c *fiber.Ctx, upload *models.ResumableUpload) error {
+	user := c.Locals("user").(*models.User)
+
+	src, err := h.Resumable.Open(upload)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	stored, err := saveUpload(c.UserContext(), src, upload.Length, upload.Filename, h.Blobs, h.Scans, user.ID)
+	if err != nil {
+		return err
+	}
+	if err := h.Resumable.Complete(upload, stored); err != nil {
+		return err
+	}
+
+	h.Scans.Enqueue(stored.ID)
+	h.Previews.Enqueue(stored.Checksum)
+	h.Indexer.Enqueue(stored.Checksum)
+	return nil
+}
+
+// DeleteResumableUpload cancels an upload and discards its data
+func (h *UploadHandler) DeleteResumableUpload(c *fiber.Ctx) error {
+	unlock, ok := h.Resumable.Lock(c.Params("id"))
+	if !ok {
+		return c.Status(fiber.StatusLocked).JSON(fiber.Map{
+			"success": false,
+			"message": "Upload is already being written",
+		})
+	}
+	defer unlock()
+
+	upload, err := h.findResumableUpload(c)
+	if upload == nil {
+		return err
+	}
+
+	if err := h.Resumable.Remove(upload); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete upload",
+		})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -4,20 +4,26 @@ import (
 	"strconv"
 
 	"synergy_dms/models"
+	"synergy_dms/services"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
-type UserHandler struct{}
+type UserHandler struct {
+	LoginGuard *services.LoginGuard
+	Workflow   *services.WorkflowEngine
+}
 
-func NewUserHandler() *UserHandler {
-	return &UserHandler{}
+func NewUserHandler(guard *services.LoginGuard, workflow *services.WorkflowEngine) *UserHandler {
+	return &UserHandler{LoginGuard: guard, Workflow: workflow}
 }
 
 // GetPendingAdmins returns list of admins pending approval (Super-Admin only)
 func (h *UserHandler) GetPendingAdmins(c *fiber.Ctx) error {
 	var users []models.User
-	if err := models.DB.Where("role = ? AND is_approved = ?", models.RoleAdmin, false).Find(&users).Error; err != nil {
+	if err := models.DB.Preload("Faculty").Where("role = ? AND is_approved = ? AND COALESCE(rejection_reason, '') = ''",
+		models.RoleAdmin, false).Find(&users).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to fetch pending admins",
@@ -47,7 +53,7 @@ func (h *UserHandler) ApproveAdmin(c *fiber.Ctx) error {
 	}
 
 	var user models.User
-	if err := models.DB.First(&user, userID).Error; err != nil {
+	if err := models.DB.Preload("Faculty").First(&user, userID).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
 			"message": "User not found",
@@ -69,6 +75,7 @@ func (h *UserHandler) ApproveAdmin(c *fiber.Ctx) error {
 	}
 
 	user.IsApproved = true
+	user.RejectionReason = ""
 	if err := models.DB.Save(&user).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
@@ -76,6 +83,9 @@ func (h *UserHandler) ApproveAdmin(c *fiber.Ctx) error {
 		})
 	}
 
+	actor := c.Locals("user").(*models.User)
+	models.RecordAudit(models.AuditAdminApproved, &actor.ID, &user.ID, c.IP(), "Admin request approved")
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"message": "Admin approved successfully",
@@ -83,11 +93,29 @@ func (h *UserHandler) ApproveAdmin(c *fiber.Ctx) error {
 	})
 }
 
-// GetAdmins returns list of all approved admins (for delegation)
+// Columns the user lists may be sorted by
+var userSortColumns = []string{"id", "email", "full_name", "role", "created_at", "updated_at"}
+
+// GetAdmins returns a page of approved admins (for delegation), filtered by
+// ?faculty_id and ?q (part of the name or email)
 func (h *UserHandler) GetAdmins(c *fiber.Ctx) error {
+	params, err := parseListParams(c, &models.User{}, userSortColumns, "full_name")
+	if params == nil {
+		return err
+	}
+
+	f := newListFilter(c, models.DB.Model(&models.User{}).
+		Where("(users.role = ? OR users.role = ?) AND users.is_approved = ? AND users.is_active = ?",
+			models.RoleAdmin, models.RoleSuperAdmin, true, true))
+	f.id("faculty_id", "users.faculty_id")
+	f.contains("q", "users.full_name", "users.email")
+	if f.bad != "" {
+		return f.respond()
+	}
+
 	var users []models.User
-	if err := models.DB.Where("(role = ? OR role = ?) AND is_approved = ?",
-		models.RoleAdmin, models.RoleSuperAdmin, true).Find(&users).Error; err != nil {
+	result, err := fetchPage(f.query, params, &users, preloadUserFaculty)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to fetch admins",
@@ -99,17 +127,37 @@ func (h *UserHandler) GetAdmins(c *fiber.Ctx) error {
 		responses = append(responses, user.ToResponse())
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"data":    responses,
-		"count":   len(responses),
-	})
+	return sendPage(c, responses, len(responses), result)
 }
 
-// GetAllUsers returns all users (Super-Admin only)
+// GetAllUsers returns a page of users (Super-Admin only), filtered by
+// ?role, ?faculty_id, ?is_active, ?is_approved, registration dates and ?q.
+// Pass ?deleted=true to list soft-deleted users instead.
 func (h *UserHandler) GetAllUsers(c *fiber.Ctx) error {
+	params, err := parseListParams(c, &models.User{}, userSortColumns, "-created_at")
+	if params == nil {
+		return err
+	}
+
+	query := models.DB.Model(&models.User{})
+	if c.Query("deleted") == "true" {
+		query = query.Unscoped().Where("users.deleted_at IS NOT NULL")
+	}
+
+	f := newListFilter(c, query)
+	f.equal("role", "users.role")
+	f.id("faculty_id", "users.faculty_id")
+	f.boolean("is_active", "users.is_active")
+	f.boolean("is_approved", "users.is_approved")
+	f.dateRange("created_from", "created_to", "users.created_at")
+	f.contains("q", "users.full_name", "users.email")
+	if f.bad != "" {
+		return f.respond()
+	}
+
 	var users []models.User
-	if err := models.DB.Find(&users).Error; err != nil {
+	result, err := fetchPage(f.query, params, &users, preloadUserFaculty)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to fetch users",
@@ -121,9 +169,92 @@ func (h *UserHandler) GetAllUsers(c *fiber.Ctx) error {
 		responses = append(responses, user.ToResponse())
 	}
 
+	return sendPage(c, responses, len(responses), result)
+}
+
+func preloadUserFaculty(db *gorm.DB) *gorm.DB {
+	return db.Preload("Faculty")
+}
+
+// UnlockUser clears a login lockout for an account (Super-Admin only)
+func (h *UserHandler) UnlockUser(c *fiber.Ctx) error {
+	actor := c.Locals("user").(*models.User)
+
+	userID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid user ID",
+		})
+	}
+
+	var user models.User
+	if err := models.DB.Preload("Faculty").First(&user, userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "User not found",
+		})
+	}
+
+	if err := h.LoginGuard.Unlock(&user, actor.ID, c.IP()); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to unlock account",
+		})
+	}
+
 	return c.JSON(fiber.Map{
 		"success": true,
-		"data":    responses,
-		"count":   len(responses),
+		"message": "Account unlocked successfully",
+		"data":    user.ToResponse(),
 	})
 }
+
+// Columns GET /users/:id/audit-log may be sorted by
+var auditSortColumns = []string{"id", "event", "created_at"}
+
+// GetUserAuditLog returns a page of the audit trail of a user: profile and
+// account changes made by the user or to the user, filtered by ?event,
+// ?actor_id and ?from / ?to (Super-Admin only)
+func (h *UserHandler) GetUserAuditLog(c *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid user ID",
+		})
+	}
+
+	params, err := parseListParams(c, &models.AuditLog{}, auditSortColumns, "-created_at")
+	if params == nil {
+		return err
+	}
+
+	f := newListFilter(c, models.DB.Model(&models.AuditLog{}).Where("audit_logs.user_id = ?", userID))
+	f.equal("event", "audit_logs.event")
+	f.id("actor_id", "audit_logs.actor_id")
+	f.dateRange("from", "to", "audit_logs.created_at")
+	if f.bad != "" {
+		return f.respond()
+	}
+
+	var entries []models.AuditLog
+	result, err := fetchPage(f.query, params, &entries, preloadAuditActor)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch audit log",
+		})
+	}
+
+	var responses []models.AuditLogResponse
+	for _, entry := range entries {
+		responses = append(responses, entry.ToResponse())
+	}
+
+	return sendPage(c, responses, len(responses), result)
+}
+
+func preloadAuditActor(db *gorm.DB) *gorm.DB {
+	return db.Preload("Actor")
+}
@@ -0,0 +1,541 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"synergy_dms/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type CreateUserRequest struct {
+	Email     string          `json:"email"`
+	Password  string          `json:"password"`
+	FullName  string          `json:"full_name"`
+	Role      models.UserRole `json:"role"`
+	FacultyID *uint           `json:"faculty_id"`
+}
+
+type UpdateUserRequest struct {
+	Email     *string `json:"email"`
+	FullName  *string `json:"full_name"`
+	FacultyID *uint   `json:"faculty_id"`
+}
+
+type ChangeRoleRequest struct {
+	Role models.UserRole `json:"role"`
+}
+
+type RejectAdminRequest struct {
+	Reason string `json:"reason"`
+}
+
+func isValidRole(role models.UserRole) bool {
+	return role == models.RoleStudent || role == models.RoleAdmin || role == models.RoleSuperAdmin
+}
+
+func isAdminRole(role models.UserRole) bool {
+	return role == models.RoleAdmin || role == models.RoleSuperAdmin
+}
+
+// findUserParam loads the user referenced by the :id route parameter
+func findUserParam(c *fiber.Ctx, unscoped bool) (*models.User, error) {
+	userID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid user ID",
+		})
+	}
+
+	query := models.DB
+	if unscoped {
+		query = query.Unscoped()
+	}
+
+	var user models.User
+	if err := query.Preload("Faculty").First(&user, userID).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "User not found",
+		})
+	}
+	return &user, nil
+}
+
+// releaseAssignedDocuments returns the user's pending documents to the
+// unassigned pool and records the change in each document's history.
+// Finished documents keep their assignee for the audit trail. Categories
+// stop assigning new documents to the user, and parallel workflow steps no
+// longer wait for their vote.
+func (h *UserHandler) releaseAssignedDocuments(userID, actorID uint, reason string) error {
+	return models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.DocumentCategory{}).Where("default_assignee_id = ?", userID).
+			Update("default_assignee_id", nil).Error; err != nil {
+			return err
+		}
+
+		var documents []models.Document
+		if err := tx.Where("assigned_to_id = ? AND status = ?", userID, models.StatusPending).
+			Find(&documents).Error; err != nil {
+			return err
+		}
+
+		for _, doc := range documents {
+			if err := tx.Model(&doc).Update("assigned_to_id", nil).Error; err != nil {
+				return err
+			}
+
+			history := models.History{
+				DocumentID: doc.ID,
+				ActorID:    actorID,
+				Action:     models.ActionUnassigned,
+				Comment:    "Returned to unassigned pool: " + reason,
+				Version:    doc.CurrentVersion,
+			}
+			if err := tx.Create(&history).Error; err != nil {
+				return err
+			}
+		}
+
+		return h.Workflow.RemoveApprover(tx, userID, actorID, reason)
+	})
+}
+
+// CreateUser creates an approved user with any role (Super-Admin only)
+func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
+	actor := c.Locals("user").(*models.User)
+
+	var req CreateUserRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Email == "" || req.Password == "" || req.FullName == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Email, password, and full name are required",
+		})
+	}
+
+	if len(req.Password) < minPasswordLength {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": fmt.Sprintf("Password must be at least %d characters", minPasswordLength),
+		})
+	}
+
+	if req.Role == "" {
+		req.Role = models.RoleStudent
+	}
+	if !isValidRole(req.Role) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid role. Must be 'student', 'admin' or 'super_admin'",
+		})
+	}
+
+	var count int64
+	models.DB.Unscoped().Model(&models.User{}).Where("LOWER(email) = LOWER(?)", req.Email).Count(&count)
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "Email already registered",
+		})
+	}
+
+	faculty, err := resolveFaculty(req.FacultyID, "")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Unknown faculty",
+		})
+	}
+
+	user := models.User{
+		Email:      req.Email,
+		FullName:   req.FullName,
+		Role:       req.Role,
+		Faculty:    faculty,
+		IsApproved: true,
+		IsActive:   true,
+	}
+	if faculty != nil {
+		user.FacultyID = &faculty.ID
+	}
+
+	if err := user.HashPassword(req.Password); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to process password",
+		})
+	}
+
+	if err := models.DB.Create(&user).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to create user",
+		})
+	}
+
+	models.RecordAudit(models.AuditUserCreated, &actor.ID, &user.ID, c.IP(),
+		fmt.Sprintf("Created %s with role %s", user.Email, user.Role))
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "User created successfully",
+		"data":    user.ToResponse(),
+	})
+}
+
+// UpdateUser edits a user's basic details (Super-Admin only)
+func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
+	actor := c.Locals("user").(*models.User)
+
+	user, err := findUserParam(c, false)
+	if user == nil {
+		return err
+	}
+
+	var req UpdateUserRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	var changes []string
+	if req.Email != nil && strings.TrimSpace(*req.Email) != user.Email {
+		email := strings.TrimSpace(*req.Email)
+		if email == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Email cannot be empty",
+			})
+		}
+
+		var count int64
+		models.DB.Unscoped().Model(&models.User{}).Where("LOWER(email) = LOWER(?) AND id <> ?", email, user.ID).Count(&count)
+		if count > 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"success": false,
+				"message": "Email already registered",
+			})
+		}
+		changes = append(changes, fmt.Sprintf("email: %q -> %q", user.Email, email))
+		user.Email = email
+	}
+
+	if req.FullName != nil && *req.FullName != user.FullName {
+		if strings.TrimSpace(*req.FullName) == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Full name cannot be empty",
+			})
+		}
+		changes = append(changes, fmt.Sprintf("full_name: %q -> %q", user.FullName, *req.FullName))
+		user.FullName = *req.FullName
+	}
+
+	if req.FacultyID != nil && !sameID(req.FacultyID, user.FacultyID) {
+		faculty, err := resolveFaculty(req.FacultyID, "")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Unknown faculty",
+			})
+		}
+		changes = append(changes, fmt.Sprintf("faculty: %q -> %q", facultyName(user.Faculty), facultyName(faculty)))
+		user.Faculty = faculty
+		user.FacultyID = nil
+		if faculty != nil {
+			user.FacultyID = &faculty.ID
+		}
+	}
+
+	if len(changes) > 0 {
+		if err := models.DB.Save(user).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to update user",
+			})
+		}
+		models.RecordAudit(models.AuditUserUpdated, &actor.ID, &user.ID, c.IP(), strings.Join(changes, "; "))
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "User updated successfully",
+		"data":    user.ToResponse(),
+	})
+}
+
+// ChangeUserRole promotes or demotes a user (Super-Admin only). The user's
+// sessions are revoked so they sign in again with the new role. Demoting an
+// admin returns their pending documents to the unassigned pool.
+func (h *UserHandler) ChangeUserRole(c *fiber.Ctx) error {
+	actor := c.Locals("user").(*models.User)
+
+	user, err := findUserParam(c, false)
+	if user == nil {
+		return err
+	}
+
+	var req ChangeRoleRequest
+	if err := c.BodyParser(&req); err != nil || !isValidRole(req.Role) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid role. Must be 'student', 'admin' or 'super_admin'",
+		})
+	}
+
+	if user.ID == actor.ID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "You cannot change your own role",
+		})
+	}
+
+	if user.Role == req.Role {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "User already has this role",
+		})
+	}
+
+	oldRole := user.Role
+	user.Role = req.Role
+	// Roles granted by a super-admin need no further approval
+	user.IsApproved = true
+	user.RejectionReason = ""
+
+	if err := models.DB.Save(user).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to change role",
+		})
+	}
+
+	models.RevokeUserSessions(user.ID)
+
+	if isAdminRole(oldRole) && !isAdminRole(user.Role) {
+		if err := h.releaseAssignedDocuments(user.ID, actor.ID, "admin rights revoked from "+user.FullName); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Role changed but failed to release assigned documents",
+			})
+		}
+	}
+
+	models.RecordAudit(models.AuditRoleChanged, &actor.ID, &user.ID, c.IP(),
+		fmt.Sprintf("Role changed from %s to %s", oldRole, user.Role))
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Role changed successfully",
+		"data":    user.ToResponse(),
+	})
+}
+
+// RejectAdmin rejects a pending admin request with a reason (Super-Admin only)
+func (h *UserHandler) RejectAdmin(c *fiber.Ctx) error {
+	actor := c.Locals("user").(*models.User)
+
+	user, err := findUserParam(c, false)
+	if user == nil {
+		return err
+	}
+
+	var req RejectAdminRequest
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.Reason) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Rejection reason is required",
+		})
+	}
+
+	if user.Role != models.RoleAdmin || user.IsApproved {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "User is not a pending admin",
+		})
+	}
+
+	user.RejectionReason = strings.TrimSpace(req.Reason)
+	if err := models.DB.Save(user).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to reject admin",
+		})
+	}
+
+	models.RevokeUserSessions(user.ID)
+	models.RecordAudit(models.AuditAdminRejected, &actor.ID, &user.ID, c.IP(), "Rejected: "+user.RejectionReason)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Admin request rejected",
+		"data":    user.ToResponse(),
+	})
+}
+
+// DeactivateUser blocks a user from signing in (Super-Admin only).
+// Their sessions are revoked and pending documents are released.
+func (h *UserHandler) DeactivateUser(c *fiber.Ctx) error {
+	actor := c.Locals("user").(*models.User)
+
+	user, err := findUserParam(c, false)
+	if user == nil {
+		return err
+	}
+
+	if user.ID == actor.ID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "You cannot deactivate your own account",
+		})
+	}
+
+	if !user.IsActive {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "User is already deactivated",
+		})
+	}
+
+	user.IsActive = false
+	if err := models.DB.Save(user).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to deactivate user",
+		})
+	}
+
+	models.RevokeUserSessions(user.ID)
+	if err := h.releaseAssignedDocuments(user.ID, actor.ID, user.FullName+" was deactivated"); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "User deactivated but failed to release assigned documents",
+		})
+	}
+
+	models.RecordAudit(models.AuditUserDeactivated, &actor.ID, &user.ID, c.IP(), "User deactivated")
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "User deactivated successfully",
+		"data":    user.ToResponse(),
+	})
+}
+
+// ActivateUser re-enables a deactivated user (Super-Admin only)
+func (h *UserHandler) ActivateUser(c *fiber.Ctx) error {
+	actor := c.Locals("user").(*models.User)
+
+	user, err := findUserParam(c, false)
+	if user == nil {
+		return err
+	}
+
+	if user.IsActive {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "User is already active",
+		})
+	}
+
+	user.IsActive = true
+	if err := models.DB.Save(user).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to activate user",
+		})
+	}
+
+	models.RecordAudit(models.AuditUserActivated, &actor.ID, &user.ID, c.IP(), "User activated")
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "User activated successfully",
+		"data":    user.ToResponse(),
+	})
+}
+
+// DeleteUser soft-deletes a user (Super-Admin only)
+func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
+	actor := c.Locals("user").(*models.User)
+
+	user, err := findUserParam(c, false)
+	if user == nil {
+		return err
+	}
+
+	if user.ID == actor.ID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "You cannot delete your own account",
+		})
+	}
+
+	if err := models.DB.Delete(user).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete user",
+		})
+	}
+
+	models.RevokeUserSessions(user.ID)
+	if err := h.releaseAssignedDocuments(user.ID, actor.ID, user.FullName+" was deleted"); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "User deleted but failed to release assigned documents",
+		})
+	}
+
+	models.RecordAudit(models.AuditUserDeleted, &actor.ID, &user.ID, c.IP(), "User deleted")
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "User deleted successfully",
+	})
+}
+
+// RestoreUser brings back a soft-deleted user (Super-Admin only)
+func (h *UserHandler) RestoreUser(c *fiber.Ctx) error {
+	actor := c.Locals("user").(*models.User)
+
+	user, err := findUserParam(c, true)
+	if user == nil {
+		return err
+	}
+
+	if !user.DeletedAt.Valid {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "User is not deleted",
+		})
+	}
+
+	if err := models.DB.Unscoped().Model(user).Update("deleted_at", nil).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to restore user",
+		})
+	}
+
+	models.RecordAudit(models.AuditUserRestored, &actor.ID, &user.ID, c.IP(), "User restored")
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "User restored successfully",
+		"data":    user.ToResponse(),
+	})
+}
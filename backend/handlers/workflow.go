@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"synergy_dms/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type WorkflowHandler struct{}
+
+func NewWorkflowHandler() *WorkflowHandler {
+	return &WorkflowHandler{}
+}
+
+type WorkflowStepRequest struct {
+	Name              string          `json:"name"`
+	ApproverRole      models.UserRole `json:"approver_role"`
+	ApproverFacultyID *uint           `json:"approver_faculty_id"`
+	ApproverUserID    *uint           `json:"approver_user_id"`
+
+	// Parallel steps: every listed approver votes
+	ApprovalMode      models.ApprovalMode `json:"approval_mode"`
+	RequiredApprovals int                 `json:"required_approvals"`
+	ApproverIDs       []uint              `json:"approver_ids"`
+}
+
+// WorkflowRequest is used for both create and update. On update, omitted
+// fields are left unchanged and an empty description clears it.
+type WorkflowRequest struct {
+	Name        string                `json:"name"`
+	Description *string               `json:"description"`
+	IsActive    *bool                 `json:"is_active"`
+	Steps       []WorkflowStepRequest `json:"steps"`
+}
+
+// buildWorkflowSteps validates step definitions and turns them into ordered steps
+func buildWorkflowSteps(reqs []WorkflowStepRequest) ([]models.WorkflowStep, string) {
+	if len(reqs) == 0 {
+		return nil, "At least one step is required"
+	}
+
+	steps := make([]models.WorkflowStep, 0, len(reqs))
+	for i, req := range reqs {
+		position := i + 1
+		name := strings.TrimSpace(req.Name)
+		if name == "" {
+			return nil, "Step " + strconv.Itoa(position) + ": name is required"
+		}
+
+		step := models.WorkflowStep{
+			Position:     position,
+			Name:         name,
+			ApprovalMode: req.ApprovalMode,
+		}
+		if step.ApprovalMode == "" {
+			step.ApprovalMode = models.ApprovalSingle
+		}
+
+		if step.IsParallel() {
+			ids := uniqueIDs(req.ApproverIDs)
+			if len(ids) == 0 {
+				return nil, "Step " + strconv.Itoa(position) + ": at least one approver is required"
+			}
+
+			if err := models.DB.Where("id IN ?", ids).Find(&step.Approvers).Error; err != nil || len(step.Approvers) != len(ids) {
+				return nil, "Step " + strconv.Itoa(position) + ": approver not found"
+			}
+			for _, approver := range step.Approvers {
+				if !isAdminRole(approver.Role) || !approver.IsApproved || !approver.IsActive {
+					return nil, "Step " + strconv.Itoa(position) + ": approvers must be active admins"
+				}
+			}
+
+			if step.ApprovalMode == models.ApprovalQuorum {
+				if req.RequiredApprovals < 1 || req.RequiredApprovals > len(ids) {
+					return nil, "Step " + strconv.Itoa(position) + ": required approvals must be between 1 and the number of approvers"
+				}
+				step.RequiredApprovals = req.RequiredApprovals
+			}
+
+			steps = append(steps, step)
+			continue
+		}
+
+		if step.ApprovalMode != models.ApprovalSingle {
+			return nil, "Step " + strconv.Itoa(position) + ": approval mode must be 'single', 'all', 'any' or 'quorum'"
+		}
+
+		if req.ApproverRole == "" && req.ApproverFacultyID == nil && req.ApproverUserID == nil {
+			return nil, "Step " + strconv.Itoa(position) + ": approver role, faculty or user is required"
+		}
+
+		if req.ApproverRole != "" && !isAdminRole(req.ApproverRole) {
+			return nil, "Step " + strconv.Itoa(position) + ": approver role must be 'admin' or 'super_admin'"
+		}
+
+		if req.ApproverFacultyID != nil {
+			var faculty models.Faculty
+			if err := models.DB.First(&faculty, *req.ApproverFacultyID).Error; err != nil {
+				return nil, "Step " + strconv.Itoa(position) + ": faculty not found"
+			}
+		}
+
+		if req.ApproverUserID != nil {
+			var approver models.User
+			if err := models.DB.First(&approver, *req.ApproverUserID).Error; err != nil ||
+				!isAdminRole(approver.Role) || !approver.IsApproved || !approver.IsActive {
+				return nil, "Step " + strconv.Itoa(position) + ": approver must be an active admin"
+			}
+		}
+
+		step.ApproverRole = req.ApproverRole
+		step.ApproverFacultyID = req.ApproverFacultyID
+		step.ApproverUserID = req.ApproverUserID
+		steps = append(steps, step)
+	}
+
+	return steps, ""
+}
+
+// GetWorkflows returns workflow definitions. Non-admins only see active ones.
+func (h *WorkflowHandler) GetWorkflows(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	query := models.PreloadWorkflowSteps(models.DB)
+	if user.Role != models.RoleSuperAdmin {
+		query = query.Where("is_active = ?", true)
+	}
+
+	var workflows []models.WorkflowDefinition
+	if err := query.Order("name ASC").Find(&workflows).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch workflows",
+		})
+	}
+
+	var responses []models.WorkflowResponse
+	for _, workflow := range workflows {
+		responses = append(responses, workflow.ToResponse())
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    responses,
+		"count":   len(responses),
+	})
+}
+
+// GetWorkflow returns a single workflow definition. Like GetWorkflows,
+// inactive workflows are only visible to super-admins.
+func (h *WorkflowHandler) GetWorkflow(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	workflowID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid workflow ID",
+		})
+	}
+
+	query := models.PreloadWorkflowSteps(models.DB)
+	if user.Role != models.RoleSuperAdmin {
+		query = query.Where("is_active = ?", true)
+	}
+
+	var workflow models.WorkflowDefinition
+	if err := query.First(&workflow, workflowID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Workflow not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    workflow.ToResponse(),
+	})
+}
+
+// CreateWorkflow creates a workflow definition with its steps (Super-Admin only)
+func (h *WorkflowHandler) CreateWorkflow(c *fiber.Ctx) error {
+	var req WorkflowRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Name is required",
+		})
+	}
+
+	steps, problem := buildWorkflowSteps(req.Steps)
+	if problem != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": problem,
+		})
+	}
+
+	var count int64
+	models.DB.Unscoped().Model(&models.WorkflowDefinition{}).Where("name = ?", req.Name).Count(&count)
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "Workflow with this name already exists",
+		})
+	}
+
+	workflow := models.WorkflowDefinition{
+		Name:     req.Name,
+		IsActive: req.IsActive == nil || *req.IsActive,
+		Steps:    steps,
+	}
+	if req.Description != nil {
+		workflow.Description = *req.Description
+	}
+
+	// Approvers already exist; only the join rows are written
+	if err := models.DB.Omit("Steps.Approvers.*").Create(&workflow).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to create workflow",
+		})
+	}
+
+	models.PreloadWorkflowSteps(models.DB).First(&workflow, workflow.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Workflow created successfully",
+		"data":    workflow.ToResponse(),
+	})
+}
+
+// UpdateWorkflow edits a workflow definition (Super-Admin only). Steps can
+// only be replaced while no pending document is using the workflow.
+func (h *WorkflowHandler) UpdateWorkflow(c *fiber.Ctx) error {
+	workflowID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid workflow ID",
+		})
+	}
+
+	var workflow models.WorkflowDefinition
+	if err := models.DB.First(&workflow, workflowID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Workflow not found",
+		})
+	}
+
+	var req WorkflowRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	if name := strings.TrimSpace(req.Name); name != "" && name != workflow.Name {
+		var count int64
+		models.DB.Unscoped().Model(&models.WorkflowDefinition{}).Where("name = ? AND id <> ?", name, workflow.ID).Count(&count)
+		if count > 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"success": false,
+				"message": "Workflow with this name already exists",
+			})
+		}
+		workflow.Name = name
+	}
+	if req.Description != nil {
+		workflow.Description = *req.Description
+	}
+	if req.IsActive != nil {
+		workflow.IsActive = *req.IsActive
+	}
+
+	var steps []models.WorkflowStep
+	if req.Steps != nil {
+		var inFlight int64
+		models.DB.Model(&models.Document{}).
+			Where("workflow_id = ? AND status = ?", workflow.ID, models.StatusPending).Count(&inFlight)
+		if inFlight > 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"success": false,
+				"message": "Steps cannot be changed while documents are in this workflow",
+			})
+		}
+
+		var problem string
+		steps, problem = buildWorkflowSteps(req.Steps)
+		if problem != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": problem,
+			})
+		}
+	}
+
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&workflow).Error; err != nil {
+			return err
+		}
+		if steps == nil {
+			return nil
+		}
+
+		oldSteps := tx.Model(&models.WorkflowStep{}).Select("id").Where("workflow_id = ?", workflow.ID)
+		if err := tx.Exec("DELETE FROM workflow_step_approvers WHERE workflow_step_id IN (?)", oldSteps).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("workflow_id = ?", workflow.ID).Delete(&models.WorkflowStep{}).Error; err != nil {
+			return err
+		}
+		for i := range steps {
+			steps[i].WorkflowID = workflow.ID
+		}
+		return tx.Omit("Approvers.*").Create(&steps).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update workflow",
+		})
+	}
+
+	models.PreloadWorkflowSteps(models.DB).First(&workflow, workflow.ID)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Workflow updated successfully",
+		"data":    workflow.ToResponse(),
+	})
+}
+
+// DeleteWorkflow removes a workflow definition (Super-Admin only).
+// Documents already on it keep going through its steps.
+func (h *WorkflowHandler) DeleteWorkflow(c *fiber.Ctx) error {
+	workflowID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid workflow ID",
+		})
+	}
+
+	result := models.DB.Delete(&models.WorkflowDefinition{}, workflowID)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete workflow",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Workflow not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Workflow deleted successfully",
+	})
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"synergy_dms/config"
@@ -12,6 +14,7 @@ import (
 	"synergy_dms/middleware"
 	"synergy_dms/models"
 	"synergy_dms/services"
+	"synergy_dms/storage"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -51,10 +54,14 @@ func main() {
 	expirationService.Start()
 
 	// Create Fiber app
+	// Bodies are streamed so tus chunks are written as they arrive; the
+	// limit for the other routes is enforced by middleware.BodyLimit
 	app := fiber.New(fiber.Config{
-		AppName:      "Synergy DMS v1.0",
-		ErrorHandler: customErrorHandler,
-		BodyLimit:    50 * 1024 * 1024, // 50MB
+		AppName:                      "Synergy DMS v1.0",
+		ErrorHandler:                 customErrorHandler,
+		BodyLimit:                    maxBodySize,
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
 	})
 
 	// Middleware
@@ -64,25 +71,79 @@ func main() {
 	}))
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
-		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders: "Origin,Content-Type,Accept,Authorization",
+		AllowMethods: "GET,HEAD,POST,PUT,PATCH,DELETE,OPTIONS",
+		AllowHeaders: "Origin,Content-Type,Accept,Authorization," +
+			"Tus-Resumable,Upload-Length,Upload-Metadata,Upload-Offset",
+		ExposeHeaders: "Location,Tus-Resumable,Tus-Version,Tus-Extension,Tus-Max-Size," +
+			"Upload-Offset,Upload-Length,Upload-Expires",
 	}))
+	app.Use(middleware.BodyLimit(maxBodySize, isTusChunk))
 
-	// Static file serving for uploads
-	uploadDir := "./uploads"
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		log.Fatalf("❌ Failed to create uploads directory: %v", err)
+	// Uploaded files are only served through access-checked document routes
+	store, err := storage.New(cfg, cfg.StorageBackend)
+	if err != nil {
+		log.Fatalf("❌ Failed to initialize %s storage: %v", cfg.StorageBackend, err)
 	}
-	app.Static("/uploads", uploadDir)
+	log.Printf("🗄️  File storage: %s", cfg.StorageBackend)
+
+	// Start antivirus scanning of uploads
+	var scanner services.Scanner
+	if cfg.ClamdAddress != "" {
+		clamd, err := services.NewClamdScanner(cfg.ClamdAddress, cfg.ClamdTimeout)
+		if err != nil {
+			log.Fatalf("❌ Failed to configure antivirus scanner: %v", err)
+		}
+		if err := clamd.Ping(context.Background()); err != nil {
+			log.Printf("⚠️  clamd at %s is not reachable yet, uploads stay quarantined: %v", cfg.ClamdAddress, err)
+		}
+		scanner = clamd
+	}
+	scanService := services.NewScanService(scanner, store, cfg.ScanWorkers, cfg.ClamdTimeout)
+	scanService.Start()
+
+	// Partial data of resumable uploads stays on local disk until finished
+	resumableUploads, err := services.NewResumableUploads(cfg.ResumableUploadDir, cfg.ResumableUploadTTL,
+		cfg.ResumableUploadMaxSize)
+	if err != nil {
+		log.Fatalf("❌ Failed to initialize resumable uploads: %v", err)
+	}
+	resumableUploads.Start()
+
+	// Uploads are stored once per content hash and re-verified periodically
+	blobs := services.NewBlobs(store)
+	integrityVerifier := services.NewIntegrityVerifier(store, cfg.IntegrityCheckInterval)
+	integrityVerifier.Start()
+	orphanCleanup := services.NewOrphanCleanupService(blobs, store, cfg.OrphanGracePeriod, cfg.OrphanCleanupInterval)
+	orphanCleanup.Start()
+	previewService := services.NewPreviewService(store, cfg.PreviewWorkers)
+	previewService.Start()
+	textIndexer := services.NewTextIndexer(store, cfg.TextIndexWorkers)
+	textIndexer.Start()
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(cfg)
-	userHandler := handlers.NewUserHandler()
-	documentHandler := handlers.NewDocumentHandler()
-	uploadHandler := handlers.NewUploadHandler(uploadDir)
+	loginGuard := services.NewLoginGuard(cfg)
+	mailer := services.NewMailer(cfg)
+	authHandler := handlers.NewAuthHandler(cfg, mailer, loginGuard)
+	workflowEngine := services.NewWorkflowEngine()
+	userHandler := handlers.NewUserHandler(loginGuard, workflowEngine)
+	versioner := services.NewVersioner(store)
+	if err := versioner.Backfill(); err != nil {
+		log.Fatalf("❌ Failed to backfill document versions: %v", err)
+	}
+	documentHandler := handlers.NewDocumentHandler(workflowEngine, versioner, services.NewURLSigner(cfg), store,
+		scanService, blobs, previewService, textIndexer)
+	uploadHandler := handlers.NewUploadHandler(blobs, scanService, resumableUploads, previewService, textIndexer)
+	settingsHandler := handlers.NewSettingsHandler()
+	facultyHandler := handlers.NewFacultyHandler()
+	workflowHandler := handlers.NewWorkflowHandler()
+	categoryHandler := handlers.NewCategoryHandler()
+	documentTypeHandler := handlers.NewDocumentTypeHandler()
+	commentHandler := handlers.NewCommentHandler(cfg, mailer)
+	storageHandler := handlers.NewStorageHandler(integrityVerifier, orphanCleanup, scanService)
 
 	// Routes
-	setupRoutes(app, authHandler, userHandler, documentHandler, uploadHandler)
+	setupRoutes(app, authHandler, userHandler, documentHandler, uploadHandler, settingsHandler, facultyHandler,
+		workflowHandler, commentHandler, storageHandler, categoryHandler, documentTypeHandler)
 
 	// Graceful shutdown
 	go func() {
@@ -92,6 +153,12 @@ func main() {
 
 		log.Println("🛑 Shutting down server...")
 		expirationService.Stop()
+		scanService.Stop()
+		resumableUploads.Stop()
+		integrityVerifier.Stop()
+		orphanCleanup.Stop()
+		previewService.Stop()
+		textIndexer.Stop()
 		app.Shutdown()
 	}()
 
@@ -101,15 +168,55 @@ func main() {
 	log.Println("📝 API Documentation:")
 	log.Println("   - POST /auth/register - Register new user")
 	log.Println("   - POST /auth/login - Login")
+	log.Println("   - POST /auth/refresh - Refresh access token")
+	log.Println("   - POST /auth/forgot-password - Request password reset")
+	log.Println("   - POST /auth/reset-password - Reset password with token")
+	log.Println("   - PUT  /auth/profile - Update own profile")
+	log.Println("   - POST /auth/email-change - Request email change")
+	log.Println("   - PUT  /auth/password - Change password")
+	log.Println("   - POST /auth/mfa/enroll - Start TOTP enrollment")
+	log.Println("   - POST /auth/mfa/verify - Complete two-step login")
+	log.Println("   - POST /auth/logout - Logout (current session)")
+	log.Println("   - POST /auth/logout-all - Logout from all sessions")
 	log.Println("   - GET  /users/pending-admins - Get pending admins (Super-Admin)")
 	log.Println("   - PUT  /users/:id/approve - Approve admin (Super-Admin)")
+	log.Println("   - PUT  /users/:id/reject - Reject admin with reason (Super-Admin)")
+	log.Println("   - PUT  /users/:id/unlock - Unlock locked-out account (Super-Admin)")
+	log.Println("   - PUT  /users/:id/role - Change user role (Super-Admin)")
+	log.Println("   - PUT  /users/:id/deactivate - Deactivate user (Super-Admin)")
+	log.Println("   - POST /users/:id/restore - Restore deleted user (Super-Admin)")
 	log.Println("   - GET  /users/admins - Get all admins")
+	log.Println("   - GET  /users/:id/audit-log - Get user audit log (Super-Admin)")
+	log.Println("   - GET  /faculties - List faculties")
+	log.Println("   - POST /faculties - Create faculty (Super-Admin)")
+	log.Println("   - PUT  /users/:id/faculties - Assign faculties to admin (Super-Admin)")
 	log.Println("   - GET  /documents - Get documents")
+	log.Println("   - GET  /documents/search?q= - Full-text search")
 	log.Println("   - POST /documents - Create document")
+	log.Println("   - PUT  /documents/:id - Edit document")
+	log.Println("   - POST /documents/:id/resubmit - Resubmit rejected document")
 	log.Println("   - PUT  /documents/:id/status - Update status")
 	log.Println("   - PUT  /documents/:id/delegate - Delegate document")
 	log.Println("   - GET  /documents/:id/history - Get history")
+	log.Println("   - GET  /documents/:id/versions - List file versions")
+	log.Println("   - GET  /documents/:id/files/:fileId - Download file")
+	log.Println("   - GET  /documents/:id/preview - File preview image")
+	log.Println("   - GET  /documents/:id/attachments - List attachments")
+	log.Println("   - GET  /documents/:id/comments - Get comments")
 	log.Println("   - POST /api/upload - Upload file")
+	log.Println("   - GET  /api/upload/:filename - Upload scan status")
+	log.Println("   - POST /api/uploads - Start resumable (tus) upload")
+	log.Println("   - GET  /workflows - List approval workflows")
+	log.Println("   - POST /workflows - Create workflow (Super-Admin)")
+	log.Println("   - GET  /categories - List document categories")
+	log.Println("   - POST /categories - Create category (Super-Admin)")
+	log.Println("   - GET  /document-types - List document types")
+	log.Println("   - POST /document-types - Create document type (Super-Admin)")
+	log.Println("   - PUT  /settings/mfa - Require MFA for roles (Super-Admin)")
+	log.Println("   - PUT  /settings/upload-types - Allowed upload types (Super-Admin)")
+	log.Println("   - GET  /storage/integrity - Blob integrity report (Super-Admin)")
+	log.Println("   - GET  /storage/orphans - Orphaned uploads dry run (Super-Admin)")
+	log.Println("   - GET  /storage/scan-errors - Uploads the scanner gave up on (Super-Admin)")
 
 	if err := app.Listen(serverAddr); err != nil {
 		log.Fatalf("❌ Server failed to start: %v", err)
@@ -117,7 +224,11 @@ func main() {
 }
 
 func setupRoutes(app *fiber.App, authHandler *handlers.AuthHandler, userHandler *handlers.UserHandler,
-	documentHandler *handlers.DocumentHandler, uploadHandler *handlers.UploadHandler) {
+	documentHandler *handlers.DocumentHandler, uploadHandler *handlers.UploadHandler,
+	settingsHandler *handlers.SettingsHandler, facultyHandler *handlers.FacultyHandler,
+	workflowHandler *handlers.WorkflowHandler, commentHandler *handlers.CommentHandler,
+	storageHandler *handlers.StorageHandler, categoryHandler *handlers.CategoryHandler,
+	documentTypeHandler *handlers.DocumentTypeHandler) {
 
 	// Health check
 	app.Get("/health", func(c *fiber.Ctx) error {
@@ -132,32 +243,144 @@ func setupRoutes(app *fiber.App, authHandler *handlers.AuthHandler, userHandler
 	auth := app.Group("/auth")
 	auth.Post("/register", authHandler.Register)
 	auth.Post("/login", authHandler.Login)
+	auth.Post("/refresh", authHandler.Refresh)
+	auth.Post("/forgot-password", authHandler.ForgotPassword)
+	auth.Post("/reset-password", authHandler.ResetPassword)
+	auth.Post("/mfa/verify", authHandler.VerifyMFA)
+	auth.Post("/email-change/confirm", authHandler.ConfirmEmailChange)
+
+	// Faculty list (public, used by the registration form)
+	app.Get("/faculties", facultyHandler.GetFaculties)
+
+	// Signed download links (public, the signature grants access)
+	app.Get("/files/:docId/:fileId", documentHandler.DownloadSignedFile)
 
 	// Protected routes
 	api := app.Group("/", middleware.AuthRequired())
 
 	// Profile
 	api.Get("auth/profile", authHandler.GetProfile)
+	api.Put("auth/profile", authHandler.UpdateProfile)
+	api.Post("auth/email-change", authHandler.RequestEmailChange)
+	api.Put("auth/password", authHandler.ChangePassword)
+	api.Post("auth/logout", authHandler.Logout)
+	api.Post("auth/logout-all", authHandler.LogoutAll)
+
+	// Two-factor authentication
+	api.Post("auth/mfa/enroll", authHandler.EnrollMFA)
+	api.Post("auth/mfa/confirm", authHandler.ConfirmMFA)
+	api.Post("auth/mfa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+	api.Post("auth/mfa/disable", authHandler.DisableMFA)
 
 	// User routes
 	users := api.Group("/users")
 	users.Get("/pending-admins", middleware.SuperAdminOnly(), userHandler.GetPendingAdmins)
 	users.Put("/:id/approve", middleware.SuperAdminOnly(), userHandler.ApproveAdmin)
+	users.Put("/:id/unlock", middleware.SuperAdminOnly(), userHandler.UnlockUser)
+	users.Put("/:id/faculties", middleware.SuperAdminOnly(), facultyHandler.SetManagedFaculties)
 	users.Get("/admins", userHandler.GetAdmins)
+	users.Get("/:id/audit-log", middleware.SuperAdminOnly(), userHandler.GetUserAuditLog)
 	users.Get("/", middleware.SuperAdminOnly(), userHandler.GetAllUsers)
+	users.Post("/", middleware.SuperAdminOnly(), userHandler.CreateUser)
+	users.Put("/:id", middleware.SuperAdminOnly(), userHandler.UpdateUser)
+	users.Delete("/:id", middleware.SuperAdminOnly(), userHandler.DeleteUser)
+	users.Put("/:id/role", middleware.SuperAdminOnly(), userHandler.ChangeUserRole)
+	users.Put("/:id/reject", middleware.SuperAdminOnly(), userHandler.RejectAdmin)
+	users.Put("/:id/deactivate", middleware.SuperAdminOnly(), userHandler.DeactivateUser)
+	users.Put("/:id/activate", middleware.SuperAdminOnly(), userHandler.ActivateUser)
+	users.Post("/:id/restore", middleware.SuperAdminOnly(), userHandler.RestoreUser)
 
 	// Document routes
 	documents := api.Group("/documents")
 	documents.Get("/", documentHandler.GetDocuments)
+	documents.Get("/search", documentHandler.SearchDocuments)
 	documents.Post("/", documentHandler.CreateDocument)
 	documents.Get("/:id", documentHandler.GetDocument)
+	documents.Put("/:id", documentHandler.UpdateDocument)
+	documents.Post("/:id/resubmit", documentHandler.ResubmitDocument)
 	documents.Put("/:id/status", middleware.AdminOrSuperAdmin(), documentHandler.UpdateDocumentStatus)
 	documents.Put("/:id/delegate", middleware.AdminOrSuperAdmin(), documentHandler.DelegateDocument)
 	documents.Get("/:id/history", documentHandler.GetDocumentHistory)
+	documents.Get("/:id/versions", documentHandler.GetDocumentVersions)
+	documents.Get("/:id/versions/:version/download", documentHandler.DownloadDocumentVersion)
+	documents.Post("/:id/versions/:version/restore", documentHandler.RestoreDocumentVersion)
+	documents.Get("/:id/files/:fileId", documentHandler.GetDocumentFile)
+	documents.Post("/:id/files/:fileId/link", documentHandler.CreateFileLink)
+	documents.Get("/:id/preview", documentHandler.GetDocumentPreview)
+	documents.Get("/:id/attachments", documentHandler.GetAttachments)
+	documents.Post("/:id/attachments", documentHandler.AddAttachments)
+	documents.Get("/:id/attachments/:attachmentId/download", documentHandler.DownloadAttachment)
+	documents.Delete("/:id/attachments/:attachmentId", documentHandler.DeleteAttachment)
+	documents.Get("/:id/comments", commentHandler.GetComments)
+	documents.Post("/:id/comments", commentHandler.CreateComment)
+	documents.Put("/:id/comments/:commentId", commentHandler.UpdateComment)
+	documents.Delete("/:id/comments/:commentId", commentHandler.DeleteComment)
+
+	// Faculty routes (Super-Admin only)
+	faculties := api.Group("/faculties", middleware.SuperAdminOnly())
+	faculties.Post("/", facultyHandler.CreateFaculty)
+	faculties.Put("/:id", facultyHandler.UpdateFaculty)
+	faculties.Delete("/:id", facultyHandler.DeleteFaculty)
+
+	// Workflow routes
+	workflows := api.Group("/workflows")
+	workflows.Get("/", workflowHandler.GetWorkflows)
+	workflows.Get("/:id", workflowHandler.GetWorkflow)
+	workflows.Post("/", middleware.SuperAdminOnly(), workflowHandler.CreateWorkflow)
+	workflows.Put("/:id", middleware.SuperAdminOnly(), workflowHandler.UpdateWorkflow)
+	workflows.Delete("/:id", middleware.SuperAdminOnly(), workflowHandler.DeleteWorkflow)
+
+	// Document category routes
+	categories := api.Group("/categories")
+	categories.Get("/", categoryHandler.GetCategories)
+	categories.Post("/", middleware.SuperAdminOnly(), categoryHandler.CreateCategory)
+	categories.Put("/:id", middleware.SuperAdminOnly(), categoryHandler.UpdateCategory)
+	categories.Delete("/:id", middleware.SuperAdminOnly(), categoryHandler.DeleteCategory)
+
+	// Document type routes
+	documentTypes := api.Group("/document-types")
+	documentTypes.Get("/", documentTypeHandler.GetDocumentTypes)
+	documentTypes.Post("/", middleware.SuperAdminOnly(), documentTypeHandler.CreateDocumentType)
+	documentTypes.Put("/:id", middleware.SuperAdminOnly(), documentTypeHandler.UpdateDocumentType)
+	documentTypes.Delete("/:id", middleware.SuperAdminOnly(), documentTypeHandler.DeleteDocumentType)
+
+	// Settings routes (Super-Admin only)
+	settings := api.Group("/settings", middleware.SuperAdminOnly())
+	settings.Get("/mfa", settingsHandler.GetMFAPolicy)
+	settings.Put("/mfa", settingsHandler.UpdateMFAPolicy)
+	settings.Get("/upload-types", settingsHandler.GetUploadTypes)
+	settings.Put("/upload-types", settingsHandler.UpdateUploadTypes)
+
+	// Storage routes (Super-Admin only)
+	storageRoutes := api.Group("/storage", middleware.SuperAdminOnly())
+	storageRoutes.Get("/integrity", storageHandler.GetIntegrityReport)
+	storageRoutes.Post("/integrity/verify", storageHandler.VerifyIntegrity)
+	storageRoutes.Get("/orphans", storageHandler.GetOrphans)
+	storageRoutes.Get("/scan-errors", storageHandler.GetScanErrors)
+	storageRoutes.Post("/scan-errors/:filename/rescan", storageHandler.RescanUpload)
 
 	// Upload route
 	upload := api.Group("/api")
 	upload.Post("/upload", uploadHandler.UploadFile)
+	upload.Get("/upload/:filename", uploadHandler.GetUploadStatus)
+
+	// Resumable uploads (tus protocol)
+	tus := upload.Group("/uploads", uploadHandler.TusProtocol)
+	tus.Options("/", uploadHandler.TusOptions)
+	tus.Post("/", uploadHandler.CreateResumableUpload)
+	tus.Head("/:id", uploadHandler.GetResumableUploadOffset)
+	tus.Get("/:id", uploadHandler.GetResumableUpload)
+	tus.Patch("/:id", uploadHandler.PatchResumableUpload)
+	tus.Delete("/:id", uploadHandler.DeleteResumableUpload)
+}
+
+// Largest request body accepted outside of tus chunks
+const maxBodySize = 50 * 1024 * 1024 // 50MB
+
+// isTusChunk reports whether the request carries a tus chunk, which is
+// written to disk as it arrives and may be of any size
+func isTusChunk(c *fiber.Ctx) bool {
+	return c.Method() == fiber.MethodPatch && strings.HasPrefix(c.Path(), "/api/uploads/")
 }
 
 func customErrorHandler(c *fiber.Ctx, err error) error {
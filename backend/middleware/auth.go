@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"strings"
 	"time"
 
@@ -12,25 +13,54 @@ import (
 )
 
 type JWTClaims struct {
-	UserID uint            `json:"user_id"`
-	Email  string          `json:"email"`
-	Role   models.UserRole `json:"role"`
+	UserID    uint            `json:"user_id"`
+	Email     string          `json:"email"`
+	Role      models.UserRole `json:"role"`
+	SessionID uint            `json:"sid"`
 	jwt.RegisteredClaims
 }
 
+// MFAClaims identify a user who passed the password check but still has to
+// present a second factor. They cannot be used as an access token.
+type MFAClaims struct {
+	UserID  uint   `json:"user_id"`
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+const mfaPendingPurpose = "mfa_pending"
+
 var jwtSecret []byte
 
 func SetJWTSecret(secret string) {
 	jwtSecret = []byte(secret)
 }
 
-func GenerateToken(user *models.User, cfg *config.Config) (string, error) {
+// GenerateToken issues a short-lived access token bound to a session
+func GenerateToken(user *models.User, session *models.Session, cfg *config.Config) (string, error) {
 	claims := JWTClaims{
-		UserID: user.ID,
-		Email:  user.Email,
-		Role:   user.Role,
+		UserID:    user.ID,
+		Email:     user.Email,
+		Role:      user.Role,
+		SessionID: session.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(cfg.AccessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "synergy_dms",
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(cfg.JWTSecret))
+}
+
+// GenerateMFAToken issues a short-lived "mfa pending" token for the second login step
+func GenerateMFAToken(user *models.User, cfg *config.Config) (string, error) {
+	claims := MFAClaims{
+		UserID:  user.ID,
+		Purpose: mfaPendingPurpose,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(5 * time.Minute)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    "synergy_dms",
 		},
@@ -40,6 +70,25 @@ func GenerateToken(user *models.User, cfg *config.Config) (string, error) {
 	return token.SignedString([]byte(cfg.JWTSecret))
 }
 
+// ParseMFAToken validates an "mfa pending" token and returns the user ID it was issued for
+func ParseMFAToken(tokenString string) (uint, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &MFAClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	})
+	if err != nil || !token.Valid {
+		return 0, errors.New("invalid or expired MFA token")
+	}
+
+	claims, ok := token.Claims.(*MFAClaims)
+	if !ok || claims.Purpose != mfaPendingPurpose || claims.UserID == 0 {
+		return 0, errors.New("invalid MFA token")
+	}
+	return claims.UserID, nil
+}
+
+// mfaEnrollmentPaths stay reachable for users who must enroll in MFA but have not yet
+var mfaEnrollmentPaths = []string{"/auth/mfa", "/auth/profile", "/auth/logout"}
+
 func AuthRequired() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
@@ -77,15 +126,39 @@ func AuthRequired() fiber.Handler {
 			})
 		}
 
+		// Reject tokens whose session was revoked or has expired
+		var session models.Session
+		if claims.SessionID == 0 || models.DB.First(&session, claims.SessionID).Error != nil ||
+			session.UserID != claims.UserID || !session.IsActive() {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"success": false,
+				"message": "Session has been revoked",
+			})
+		}
+
 		// Check if user is approved (for admins)
 		var user models.User
-		if err := models.DB.First(&user, claims.UserID).Error; err != nil {
+		if err := models.DB.Preload("Faculty").Preload("ManagedFaculties").First(&user, claims.UserID).Error; err != nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"success": false,
 				"message": "User not found",
 			})
 		}
 
+		if !user.IsActive {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"success": false,
+				"message": "Your account has been deactivated",
+			})
+		}
+
+		if user.IsRejected() {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"success": false,
+				"message": "Your admin request was rejected: " + user.RejectionReason,
+			})
+		}
+
 		if !user.IsApproved {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 				"success": false,
@@ -93,16 +166,36 @@ func AuthRequired() fiber.Handler {
 			})
 		}
 
-		// Store user info in context
-		c.Locals("userID", claims.UserID)
-		c.Locals("userEmail", claims.Email)
-		c.Locals("userRole", claims.Role)
+		// Users whose role requires MFA can only reach enrollment until they enroll
+		if !user.MFAEnabled && models.MFARequiredForRole(user.Role) && !isMFAEnrollmentPath(c.Path()) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"success":                 false,
+				"message":                 "Two-factor authentication must be enabled for your account",
+				"mfa_enrollment_required": true,
+			})
+		}
+
+		// Store user info in context. Email and role come from the database,
+		// so changes apply before the token expires.
+		c.Locals("userID", user.ID)
+		c.Locals("userEmail", user.Email)
+		c.Locals("userRole", user.Role)
+		c.Locals("sessionID", claims.SessionID)
 		c.Locals("user", &user)
 
 		return c.Next()
 	}
 }
 
+func isMFAEnrollmentPath(path string) bool {
+	for _, prefix := range mfaEnrollmentPaths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 func RoleRequired(roles ...models.UserRole) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		userRole := c.Locals("userRole").(models.UserRole)
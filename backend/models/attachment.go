@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+)
+
+// DocumentAttachment is an additional file attached to a document, e.g. a
+// passport scan next to the application itself. The document's FilePath
+// remains the primary file.
+type DocumentAttachment struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	DocumentID   uint      `gorm:"not null;index" json:"document_id"`
+	StoredName   string    `gorm:"size:255;not null" json:"stored_name"`
+	OriginalName string    `gorm:"size:255;not null" json:"original_name"`
+	MimeType     string    `gorm:"size:255" json:"mime_type"`
+	Size         int64     `gorm:"not null" json:"size"`
+	Checksum     string    `gorm:"size:64;not null" json:"checksum"`
+	UploadedByID uint      `gorm:"not null" json:"uploaded_by_id"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	// Antivirus verdict, kept in sync with the upload record
+	ScanStatus    ScanStatus `gorm:"size:20;not null;default:'skipped'" json:"scan_status"`
+	ScanSignature string     `gorm:"size:255" json:"scan_signature,omitempty"`
+
+	// Relations
+	UploadedBy User  `gorm:"foreignKey:UploadedByID" json:"uploaded_by,omitempty"`
+	Blob       *Blob `gorm:"foreignKey:Checksum;references:Hash;constraint:-" json:"-"`
+}
+
+type DocumentAttachmentResponse struct {
+	ID             uint      `json:"id"`
+	DocumentID     uint      `json:"document_id"`
+	OriginalName   string    `json:"original_name"`
+	MimeType       string    `json:"mime_type"`
+	Size           int64     `json:"size"`
+	Checksum       string    `json:"checksum"`
+	UploadedByID   uint      `json:"uploaded_by_id"`
+	UploadedByName string    `json:"uploaded_by_name,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+
+	ScanStatus    ScanStatus    `json:"scan_status"`
+	ScanSignature string        `json:"scan_signature,omitempty"`
+	PreviewStatus PreviewStatus `json:"preview_status,omitempty"`
+}
+
+func (a *DocumentAttachment) ToResponse() DocumentAttachmentResponse {
+	resp := DocumentAttachmentResponse{
+		ID:             a.ID,
+		DocumentID:     a.DocumentID,
+		OriginalName:   a.OriginalName,
+		MimeType:       a.MimeType,
+		Size:           a.Size,
+		Checksum:       a.Checksum,
+		UploadedByID:   a.UploadedByID,
+		UploadedByName: a.UploadedBy.FullName,
+		CreatedAt:      a.CreatedAt,
+
+		ScanStatus:    a.ScanStatus,
+		ScanSignature: a.ScanSignature,
+	}
+	if a.Blob != nil {
+		resp.PreviewStatus = a.Blob.PreviewStatus
+	}
+	return resp
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"log"
+	"time"
+)
+
+type AuditEvent string
+
+const (
+	AuditAccountLocked   AuditEvent = "account_locked"
+	AuditIPLocked        AuditEvent = "ip_locked"
+	AuditAccountUnlocked AuditEvent = "account_unlocked"
+	AuditUserCreated     AuditEvent = "user_created"
+	AuditUserUpdated     AuditEvent = "user_updated"
+	AuditRoleChanged     AuditEvent = "role_changed"
+	AuditUserDeactivated AuditEvent = "user_deactivated"
+	AuditUserActivated   AuditEvent = "user_activated"
+	AuditAdminApproved   AuditEvent = "admin_approved"
+	AuditAdminRejected   AuditEvent = "admin_rejected"
+	AuditUserDeleted     AuditEvent = "user_deleted"
+	AuditUserRestored    AuditEvent = "user_restored"
+	AuditProfileUpdated  AuditEvent = "profile_updated"
+	AuditEmailRequested  AuditEvent = "email_change_requested"
+	AuditEmailChanged    AuditEvent = "email_changed"
+	AuditFileInfected    AuditEvent = "file_infected"
+	AuditFileScanError   AuditEvent = "file_scan_error"
+	AuditBlobDamaged     AuditEvent = "blob_damaged"
+)
+
+// AuditLog records security-relevant and administrative events
+type AuditLog struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	Event     AuditEvent `gorm:"size:50;not null;index" json:"event"`
+	ActorID   *uint      `gorm:"index" json:"actor_id,omitempty"`
+	UserID    *uint      `gorm:"index" json:"user_id,omitempty"`
+	IPAddress string     `gorm:"size:64" json:"ip_address,omitempty"`
+	Details   string     `gorm:"type:text" json:"details,omitempty"`
+	CreatedAt time.Time  `gorm:"index" json:"created_at"`
+
+	// Relations
+	Actor *User `gorm:"foreignKey:ActorID" json:"actor,omitempty"`
+	User  *User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+type AuditLogResponse struct {
+	ID        uint       `json:"id"`
+	Event     AuditEvent `json:"event"`
+	ActorID   *uint      `json:"actor_id,omitempty"`
+	ActorName string     `json:"actor_name,omitempty"`
+	UserID    *uint      `json:"user_id,omitempty"`
+	IPAddress string     `json:"ip_address,omitempty"`
+	Details   string     `json:"details,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (a *AuditLog) ToResponse() AuditLogResponse {
+	resp := AuditLogResponse{
+		ID:        a.ID,
+		Event:     a.Event,
+		ActorID:   a.ActorID,
+		UserID:    a.UserID,
+		IPAddress: a.IPAddress,
+		Details:   a.Details,
+		CreatedAt: a.CreatedAt,
+	}
+
+	if a.Actor != nil {
+		resp.ActorName = a.Actor.FullName
+	}
+
+	return resp
+}
+
+// RecordAudit writes an audit log entry. Failures are logged, not returned,
+// so auditing never breaks the action being audited.
+func RecordAudit(event AuditEvent, actorID, userID *uint, ip, details string) {
+	entry := AuditLog{
+		Event:     event,
+		ActorID:   actorID,
+		UserID:    userID,
+		IPAddress: ip,
+		Details:   details,
+	}
+	if err := DB.Create(&entry).Error; err != nil {
+		log.Printf("❌ Failed to write audit log (%s): %v", event, err)
+	}
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BlobStatus is the result of the last integrity check of a blob
+type BlobStatus string
+
+const (
+	BlobOK        BlobStatus = "ok"
+	BlobMissing   BlobStatus = "missing"
+	BlobCorrupted BlobStatus = "corrupted"
+)
+
+// PreviewStatus tells whether thumbnails of a blob can be shown
+type PreviewStatus string
+
+const (
+	// PreviewPending: waiting for the preview service
+	PreviewPending PreviewStatus = "pending"
+	// PreviewReady: the preview and the thumbnail are stored
+	PreviewReady PreviewStatus = "ready"
+	// PreviewUnsupported: the file type has no preview
+	PreviewUnsupported PreviewStatus = "unsupported"
+	// PreviewFailed: the file could not be decoded
+	PreviewFailed PreviewStatus = "failed"
+)
+
+// Preview sizes
+const (
+	PreviewSizeThumbnail = "thumbnail"
+	PreviewSizeLarge     = "preview"
+)
+
+// Blob is file content stored once under its SHA-256 hash. RefCount counts
+// the uploads that point at it; the content is deleted when it drops to zero.
+type Blob struct {
+	Hash       string     `gorm:"primaryKey;size:64" json:"hash"`
+	Size       int64      `gorm:"not null" json:"size"`
+	MimeType   string     `gorm:"size:255" json:"mime_type"`
+	RefCount   int        `gorm:"not null" json:"ref_count"`
+	Status     BlobStatus `gorm:"size:20;not null;index" json:"status"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+
+	PreviewStatus PreviewStatus `gorm:"size:20;not null;default:'pending';index" json:"preview_status"`
+}
+
+// BlobKey is the storage key of the content with the given hash
+func BlobKey(hash string) string {
+	return "sha256-" + hash
+}
+
+// Key returns the storage key of the blob
+func (b *Blob) Key() string {
+	return BlobKey(b.Hash)
+}
+
+// PreviewKey is the storage key of a rendered preview of the content with
+// the given hash, e.g. "thumbnail-<hash>.jpg"
+func PreviewKey(hash, size string) string {
+	return size + "-" + hash + ".jpg"
+}
+
+// PreviewHash returns the content hash of a preview key, or "" for other keys
+func PreviewHash(key string) string {
+	for _, size := range []string{PreviewSizeThumbnail, PreviewSizeLarge} {
+		if hash, ok := strings.CutPrefix(key, size+"-"); ok {
+			return strings.TrimSuffix(hash, ".jpg")
+		}
+	}
+	return ""
+}
+
+// AcquireBlob adds a reference to the blob, creating it on first use. The
+// row stays locked until tx ends; it returns true when the caller has to
+// write the content because the blob is new or failed verification.
+func AcquireBlob(tx *gorm.DB, hash string, size int64, mimeType string) (bool, error) {
+	var existing Blob
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&existing, "hash = ?", hash).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	write := err != nil || existing.Status != BlobOK
+
+	blob := Blob{Hash: hash, Size: size, MimeType: mimeType, RefCount: 1, Status: BlobOK}
+	return write, tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "hash"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"ref_count":  gorm.Expr("blobs.ref_count + 1"),
+			"status":     BlobOK,
+			"updated_at": time.Now(),
+		}),
+	}).Create(&blob).Error
+}
+
+// ReleaseBlob drops a reference. It returns true when nothing refers to the
+// blob any more; the row is removed and the caller deletes the content.
+func ReleaseBlob(tx *gorm.DB, hash string) (bool, error) {
+	var blob Blob
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&blob, "hash = ?", hash).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if blob.RefCount > 1 {
+		return false, tx.Model(&blob).UpdateColumn("ref_count", gorm.Expr("ref_count - 1")).Error
+	}
+	return true, tx.Delete(&blob).Error
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DocumentCategory is an entry of the controlled vocabulary documents are
+// classified by, e.g. "Academic leave". New documents of the category take
+// its default priority and assignee.
+type DocumentCategory struct {
+	ID                uint             `gorm:"primaryKey" json:"id"`
+	Name              string           `gorm:"uniqueIndex;size:255;not null" json:"name"`
+	Description       string           `gorm:"type:text" json:"description"`
+	DefaultPriority   DocumentPriority `gorm:"default:0" json:"default_priority,omitempty"`
+	DefaultAssigneeID *uint            `gorm:"index" json:"default_assignee_id,omitempty"`
+	CreatedAt         time.Time        `json:"created_at"`
+	UpdatedAt         time.Time        `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt   `gorm:"index" json:"-"`
+
+	// Relations
+	DefaultAssignee *User `gorm:"foreignKey:DefaultAssigneeID" json:"-"`
+}
+
+type DocumentCategoryResponse struct {
+	ID                  uint             `json:"id"`
+	Name                string           `json:"name"`
+	Description         string           `json:"description"`
+	DefaultPriority     DocumentPriority `json:"default_priority,omitempty"`
+	DefaultAssigneeID   *uint            `json:"default_assignee_id,omitempty"`
+	DefaultAssigneeName string           `json:"default_assignee_name,omitempty"`
+}
+
+func (c *DocumentCategory) ToResponse() DocumentCategoryResponse {
+	resp := DocumentCategoryResponse{
+		ID:                c.ID,
+		Name:              c.Name,
+		Description:       c.Description,
+		DefaultPriority:   c.DefaultPriority,
+		DefaultAssigneeID: c.DefaultAssigneeID,
+	}
+
+	if c.DefaultAssignee != nil {
+		resp.DefaultAssigneeName = c.DefaultAssignee.FullName
+	}
+
+	return resp
+}
+
+// ActiveDefaultAssignee returns the default assignee if they can still take
+// documents. DefaultAssignee must be loaded.
+func (c *DocumentCategory) ActiveDefaultAssignee() *uint {
+	if c.DefaultAssignee == nil || !c.DefaultAssignee.IsActiveAdmin() {
+		return nil
+	}
+	return c.DefaultAssigneeID
+}
+
+// Limits for free-form tags
+const (
+	MaxTagLength       = 50
+	MaxTagsPerDocument = 20
+)
+
+// Tag is a free-form label attached to documents. Names are stored
+// normalized, so "Scholarship" and " scholarship " are the same tag.
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"uniqueIndex;size:50;not null" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NormalizeTag lowercases a tag and collapses its whitespace. It returns ""
+// for names that are empty or too long.
+func NormalizeTag(name string) string {
+	name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+	if utf8.RuneCountInString(name) > MaxTagLength {
+		return ""
+	}
+	return name
+}
+
+// FindOrCreateTags returns the tags with the given normalized names,
+// creating the missing ones
+func FindOrCreateTags(tx *gorm.DB, names []string) ([]Tag, error) {
+	tags := make([]Tag, 0, len(names))
+	for _, name := range names {
+		// Another request may be creating the same tag
+		tag := Tag{Name: name}
+		if err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "name"}}, DoNothing: true}).
+			Create(&tag).Error; err != nil {
+			return nil, err
+		}
+		if tag.ID == 0 {
+			if err := tx.Where("name = ?", name).First(&tag).Error; err != nil {
+				return nil, err
+			}
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Comment is a discussion message on a document. Replies point at their
+// parent; internal comments are only visible to admins.
+type Comment struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	DocumentID uint           `gorm:"not null;index" json:"document_id"`
+	AuthorID   uint           `gorm:"not null" json:"author_id"`
+	ParentID   *uint          `gorm:"index" json:"parent_id,omitempty"`
+	Body       string         `gorm:"type:text;not null" json:"body"`
+	IsInternal bool           `gorm:"not null" json:"is_internal"`
+	EditedAt   *time.Time     `json:"edited_at,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Relations
+	Author   User   `gorm:"foreignKey:AuthorID" json:"author,omitempty"`
+	Mentions []User `gorm:"many2many:comment_mentions" json:"-"`
+}
+
+type CommentResponse struct {
+	ID         uint              `json:"id"`
+	DocumentID uint              `json:"document_id"`
+	AuthorID   uint              `json:"author_id"`
+	AuthorName string            `json:"author_name,omitempty"`
+	ParentID   *uint             `json:"parent_id,omitempty"`
+	Body       string            `json:"body"`
+	IsInternal bool              `json:"is_internal"`
+	IsDeleted  bool              `json:"is_deleted,omitempty"`
+	Mentions   []UserResponse    `json:"mentions,omitempty"`
+	Replies    []CommentResponse `json:"replies,omitempty"`
+	EditedAt   *time.Time        `json:"edited_at,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+// ToResponse converts a comment without its replies. Deleted comments keep
+// their place in the thread but lose their content.
+func (c *Comment) ToResponse() CommentResponse {
+	resp := CommentResponse{
+		ID:         c.ID,
+		DocumentID: c.DocumentID,
+		AuthorID:   c.AuthorID,
+		AuthorName: c.Author.FullName,
+		ParentID:   c.ParentID,
+		Body:       c.Body,
+		IsInternal: c.IsInternal,
+		EditedAt:   c.EditedAt,
+		CreatedAt:  c.CreatedAt,
+	}
+
+	if c.DeletedAt.Valid {
+		resp.IsDeleted = true
+		resp.Body = ""
+		return resp
+	}
+
+	for _, user := range c.Mentions {
+		resp.Mentions = append(resp.Mentions, user.ToResponse())
+	}
+
+	return resp
+}
+
+// BuildCommentThreads nests replies under their parents. Comments must be
+// ordered oldest first; replies to comments missing from the list are dropped.
+func BuildCommentThreads(comments []Comment) []CommentResponse {
+	children := make(map[uint][]uint)
+	byID := make(map[uint]*Comment, len(comments))
+	var roots []uint
+
+	for i := range comments {
+		comment := &comments[i]
+		byID[comment.ID] = comment
+		if comment.ParentID == nil {
+			roots = append(roots, comment.ID)
+		} else {
+			children[*comment.ParentID] = append(children[*comment.ParentID], comment.ID)
+		}
+	}
+
+	var build func(id uint) CommentResponse
+	build = func(id uint) CommentResponse {
+		resp := byID[id].ToResponse()
+		for _, childID := range children[id] {
+			if _, ok := byID[childID]; ok {
+				resp.Replies = append(resp.Replies, build(childID))
+			}
+		}
+		return resp
+	}
+
+	threads := []CommentResponse{}
+	for _, id := range roots {
+		threads = append(threads, build(id))
+	}
+	return threads
+}
@@ -55,7 +55,22 @@ func ConnectDatabase(cfg *config.Config) error {
 }
 
 func AutoMigrate() error {
-	return DB.AutoMigrate(&User{}, &Document{}, &History{})
+	if err := DB.AutoMigrate(
+		&Faculty{}, &User{}, &WorkflowDefinition{}, &WorkflowStep{},
+		&DocumentCategory{}, &Tag{}, &DocumentType{},
+		&Document{}, &DocumentVersion{}, &DocumentAttachment{}, &History{}, &Comment{},
+		&Upload{}, &ResumableUpload{}, &Blob{}, &BlobText{}, &DocumentSearch{},
+		&Session{}, &PasswordResetToken{}, &EmailChangeRequest{}, &MFARecoveryCode{},
+		&Setting{}, &AuditLog{}, &LoginThrottle{},
+	); err != nil {
+		return err
+	}
+
+	if err := MigrateEmailIndex(); err != nil {
+		return err
+	}
+
+	return MigrateLegacyFaculties()
 }
 
 func SeedSuperAdmin() error {
@@ -63,11 +78,16 @@ func SeedSuperAdmin() error {
 	DB.Model(&User{}).Count(&count)
 
 	if count == 0 {
+		administration := Faculty{Name: "Administration"}
+		if err := DB.Where("name = ?", administration.Name).FirstOrCreate(&administration).Error; err != nil {
+			return fmt.Errorf("failed to create administration faculty: %w", err)
+		}
+
 		superAdmin := User{
 			Email:      "super@synergy.ru",
 			FullName:   "Super Administrator",
 			Role:       RoleSuperAdmin,
-			Faculty:    "Administration",
+			FacultyID:  &administration.ID,
 			IsApproved: true,
 		}
 
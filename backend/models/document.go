@@ -27,20 +27,36 @@ type Document struct {
 	Title           string           `gorm:"size:255;not null" json:"title"`
 	Description     string           `gorm:"type:text" json:"description"`
 	FilePath        string           `gorm:"size:500" json:"file_path"`
+	CurrentVersion  int              `gorm:"default:0" json:"current_version"`
 	Priority        DocumentPriority `gorm:"default:1" json:"priority"`
 	Status          DocumentStatus   `gorm:"size:50;default:'pending'" json:"status"`
 	RejectionReason string           `gorm:"type:text" json:"rejection_reason,omitempty"`
 	Deadline        *time.Time       `json:"deadline,omitempty"`
 	CreatorID       uint             `gorm:"not null" json:"creator_id"`
 	AssignedToID    *uint            `json:"assigned_to_id,omitempty"`
+	FacultyID       *uint            `gorm:"index" json:"faculty_id,omitempty"`
+	WorkflowID      *uint            `gorm:"index" json:"workflow_id,omitempty"`
+	CategoryID      *uint            `gorm:"index" json:"category_id,omitempty"`
+	TypeID          *uint            `gorm:"index" json:"type_id,omitempty"`
+	CustomFields    JSONMap          `gorm:"type:jsonb" json:"custom_fields,omitempty"`
+	CurrentStep     int              `gorm:"default:0" json:"current_step"`
+	StepStartedAt   *time.Time       `json:"step_started_at,omitempty"`
 	CreatedAt       time.Time        `json:"created_at"`
 	UpdatedAt       time.Time        `json:"updated_at"`
 	DeletedAt       gorm.DeletedAt   `gorm:"index" json:"-"`
 
 	// Relations
-	Creator    User      `gorm:"foreignKey:CreatorID" json:"creator,omitempty"`
-	AssignedTo *User     `gorm:"foreignKey:AssignedToID" json:"assigned_to,omitempty"`
-	History    []History `gorm:"foreignKey:DocumentID" json:"history,omitempty"`
+	Creator     User                 `gorm:"foreignKey:CreatorID" json:"creator,omitempty"`
+	AssignedTo  *User                `gorm:"foreignKey:AssignedToID" json:"assigned_to,omitempty"`
+	Faculty     *Faculty             `gorm:"foreignKey:FacultyID" json:"faculty,omitempty"`
+	Workflow    *WorkflowDefinition  `gorm:"foreignKey:WorkflowID" json:"-"`
+	Category    *DocumentCategory    `gorm:"foreignKey:CategoryID" json:"-"`
+	Type        *DocumentType        `gorm:"foreignKey:TypeID" json:"-"`
+	Tags        []Tag                `gorm:"many2many:document_tags" json:"-"`
+	History     []History            `gorm:"foreignKey:DocumentID" json:"history,omitempty"`
+	Versions    []DocumentVersion    `gorm:"foreignKey:DocumentID" json:"-"`
+	Attachments []DocumentAttachment `gorm:"foreignKey:DocumentID" json:"-"`
+	CurrentFile *DocumentVersion     `gorm:"foreignKey:DocumentID,Version;references:ID,CurrentVersion;constraint:-" json:"-"`
 }
 
 type DocumentResponse struct {
@@ -48,30 +64,73 @@ type DocumentResponse struct {
 	Title           string           `json:"title"`
 	Description     string           `json:"description"`
 	FilePath        string           `json:"file_path"`
+	CurrentVersion  int              `json:"current_version,omitempty"`
 	Priority        DocumentPriority `json:"priority"`
 	Status          DocumentStatus   `json:"status"`
 	RejectionReason string           `json:"rejection_reason,omitempty"`
 	Deadline        *time.Time       `json:"deadline,omitempty"`
 	CreatorID       uint             `json:"creator_id"`
 	AssignedToID    *uint            `json:"assigned_to_id,omitempty"`
+	FacultyID       *uint            `json:"faculty_id,omitempty"`
+	WorkflowID      *uint            `json:"workflow_id,omitempty"`
+	WorkflowName    string           `json:"workflow_name,omitempty"`
+	CategoryID      *uint            `json:"category_id,omitempty"`
+	CategoryName    string           `json:"category_name,omitempty"`
+	Tags            []string         `json:"tags"`
+	TypeID          *uint            `json:"type_id,omitempty"`
+	TypeName        string           `json:"type_name,omitempty"`
+	CustomFields    JSONMap          `json:"custom_fields,omitempty"`
+	CurrentStep     int              `json:"current_step,omitempty"`
+	CurrentStepName string           `json:"current_step_name,omitempty"`
+	TotalSteps      int              `json:"total_steps,omitempty"`
 	CreatorName     string           `json:"creator_name,omitempty"`
 	AssignedToName  string           `json:"assigned_to_name,omitempty"`
+	PreviewStatus   PreviewStatus    `json:"preview_status,omitempty"`
 	CreatedAt       time.Time        `json:"created_at"`
 	UpdatedAt       time.Time        `json:"updated_at"`
 }
 
+// PreloadDocumentRelations loads everything ToResponse needs
+func PreloadDocumentRelations(db *gorm.DB) *gorm.DB {
+	return db.Preload("Creator").Preload("AssignedTo").
+		Preload("Workflow", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped()
+		}).
+		Preload("Workflow.Steps", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position ASC")
+		}).
+		Preload("CurrentFile.Blob").
+		Preload("Category", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped()
+		}).
+		Preload("Tags", func(db *gorm.DB) *gorm.DB {
+			return db.Order("tags.name ASC")
+		}).
+		Preload("Type", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped()
+		})
+}
+
 func (d *Document) ToResponse() DocumentResponse {
 	resp := DocumentResponse{
 		ID:              d.ID,
 		Title:           d.Title,
 		Description:     d.Description,
 		FilePath:        d.FilePath,
+		CurrentVersion:  d.CurrentVersion,
 		Priority:        d.Priority,
 		Status:          d.Status,
 		RejectionReason: d.RejectionReason,
 		Deadline:        d.Deadline,
 		CreatorID:       d.CreatorID,
 		AssignedToID:    d.AssignedToID,
+		FacultyID:       d.FacultyID,
+		WorkflowID:      d.WorkflowID,
+		CategoryID:      d.CategoryID,
+		Tags:            []string{},
+		TypeID:          d.TypeID,
+		CustomFields:    d.CustomFields,
+		CurrentStep:     d.CurrentStep,
 		CreatedAt:       d.CreatedAt,
 		UpdatedAt:       d.UpdatedAt,
 	}
@@ -84,5 +143,31 @@ func (d *Document) ToResponse() DocumentResponse {
 		resp.AssignedToName = d.AssignedTo.FullName
 	}
 
+	if d.Category != nil {
+		resp.CategoryName = d.Category.Name
+	}
+
+	if d.Type != nil {
+		resp.TypeName = d.Type.Name
+	}
+
+	for _, tag := range d.Tags {
+		resp.Tags = append(resp.Tags, tag.Name)
+	}
+
+	if d.CurrentFile != nil && d.CurrentFile.Blob != nil {
+		resp.PreviewStatus = d.CurrentFile.Blob.PreviewStatus
+	}
+
+	if d.Workflow != nil {
+		resp.WorkflowName = d.Workflow.Name
+		resp.TotalSteps = len(d.Workflow.Steps)
+		for _, step := range d.Workflow.Steps {
+			if step.Position == d.CurrentStep {
+				resp.CurrentStepName = step.Name
+			}
+		}
+	}
+
 	return resp
 }
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// JSONMap is a JSON object stored in a jsonb column
+type JSONMap map[string]interface{}
+
+func (m JSONMap) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(m)
+	return string(data), err
+}
+
+func (m *JSONMap) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into JSONMap", value)
+	}
+	return json.Unmarshal(data, m)
+}
+
+// DocumentType is a kind of request with its own structured fields, e.g. an
+// academic leave request with the dates of leave. Schema is the JSON Schema
+// the custom fields of its documents must match.
+type DocumentType struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	Name        string         `gorm:"uniqueIndex;size:255;not null" json:"name"`
+	Description string         `gorm:"type:text" json:"description"`
+	Schema      JSONMap        `gorm:"type:jsonb;not null" json:"schema"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+type DocumentTypeResponse struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Schema      JSONMap   `json:"schema"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (t *DocumentType) ToResponse() DocumentTypeResponse {
+	return DocumentTypeResponse{
+		ID:          t.ID,
+		Name:        t.Name,
+		Description: t.Description,
+		Schema:      t.Schema,
+		UpdatedAt:   t.UpdatedAt,
+	}
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DocumentVersion is one file revision of a document. Revisions are never
+// overwritten; restoring an old one adds a new version pointing at its file.
+type DocumentVersion struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	DocumentID   uint      `gorm:"not null;uniqueIndex:idx_document_version" json:"document_id"`
+	Version      int       `gorm:"not null;uniqueIndex:idx_document_version" json:"version"`
+	FilePath     string    `gorm:"size:500;not null" json:"file_path"`
+	Size         int64     `gorm:"not null" json:"size"`
+	Checksum     string    `gorm:"size:64;not null" json:"checksum"`
+	UploadedByID uint      `gorm:"not null" json:"uploaded_by_id"`
+	Comment      string    `gorm:"type:text" json:"comment,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	// Relations
+	UploadedBy User  `gorm:"foreignKey:UploadedByID" json:"uploaded_by,omitempty"`
+	Blob       *Blob `gorm:"foreignKey:Checksum;references:Hash;constraint:-" json:"-"`
+}
+
+type DocumentVersionResponse struct {
+	ID             uint      `json:"id"`
+	DocumentID     uint      `json:"document_id"`
+	Version        int       `json:"version"`
+	FilePath       string    `json:"file_path"`
+	Size           int64     `json:"size"`
+	Checksum       string    `json:"checksum"`
+	UploadedByID   uint      `json:"uploaded_by_id"`
+	UploadedByName string    `json:"uploaded_by_name,omitempty"`
+	Comment        string    `json:"comment,omitempty"`
+	IsCurrent      bool      `json:"is_current"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func (v *DocumentVersion) ToResponse() DocumentVersionResponse {
+	return DocumentVersionResponse{
+		ID:             v.ID,
+		DocumentID:     v.DocumentID,
+		Version:        v.Version,
+		FilePath:       v.FilePath,
+		Size:           v.Size,
+		Checksum:       v.Checksum,
+		UploadedByID:   v.UploadedByID,
+		UploadedByName: v.UploadedBy.FullName,
+		Comment:        v.Comment,
+		CreatedAt:      v.CreatedAt,
+	}
+}
+
+// AddDocumentVersion stores a new file revision and makes it the document's
+// current file. The document itself is saved by the caller.
+func AddDocumentVersion(tx *gorm.DB, doc *Document, filePath string, size int64, checksum string,
+	uploaderID uint, comment string) (*DocumentVersion, error) {
+	var last int
+	if err := tx.Model(&DocumentVersion{}).Where("document_id = ?", doc.ID).
+		Select("COALESCE(MAX(version), 0)").Scan(&last).Error; err != nil {
+		return nil, err
+	}
+
+	version := DocumentVersion{
+		DocumentID:   doc.ID,
+		Version:      last + 1,
+		FilePath:     filePath,
+		Size:         size,
+		Checksum:     checksum,
+		UploadedByID: uploaderID,
+		Comment:      comment,
+	}
+	if err := tx.Create(&version).Error; err != nil {
+		return nil, err
+	}
+
+	doc.FilePath = filePath
+	doc.CurrentVersion = version.Version
+	return &version, nil
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Faculty is a faculty or, when ParentID is set, one of its sub-departments
+type Faculty struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	Name      string         `gorm:"uniqueIndex;size:255;not null" json:"name"`
+	Code      string         `gorm:"size:50" json:"code"`
+	ParentID  *uint          `gorm:"index" json:"parent_id,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Relations
+	Parent   *Faculty  `gorm:"foreignKey:ParentID" json:"-"`
+	Children []Faculty `gorm:"foreignKey:ParentID" json:"-"`
+}
+
+type FacultyResponse struct {
+	ID         uint   `json:"id"`
+	Name       string `json:"name"`
+	Code       string `json:"code"`
+	ParentID   *uint  `json:"parent_id,omitempty"`
+	ParentName string `json:"parent_name,omitempty"`
+}
+
+func (f *Faculty) ToResponse() FacultyResponse {
+	resp := FacultyResponse{
+		ID:       f.ID,
+		Name:     f.Name,
+		Code:     f.Code,
+		ParentID: f.ParentID,
+	}
+
+	if f.Parent != nil {
+		resp.ParentName = f.Parent.Name
+	}
+
+	return resp
+}
+
+// ExpandFacultyIDs returns the given faculties together with all of their sub-departments
+func ExpandFacultyIDs(ids []uint) []uint {
+	seen := map[uint]bool{}
+	var result []uint
+
+	frontier := ids
+	for len(frontier) > 0 {
+		var next []uint
+		for _, id := range frontier {
+			if !seen[id] {
+				seen[id] = true
+				result = append(result, id)
+				next = append(next, id)
+			}
+		}
+		if len(next) == 0 {
+			break
+		}
+
+		frontier = nil
+		DB.Model(&Faculty{}).Where("parent_id IN ?", next).Pluck("id", &frontier)
+	}
+
+	return result
+}
+
+// MigrateLegacyFaculties converts the old free-text users.faculty column into
+// Faculty entities, links users and documents to them by ID and then drops
+// the old column. It is a no-op once the column is gone.
+func MigrateLegacyFaculties() error {
+	if DB.Migrator().HasColumn(&User{}, "faculty") {
+		var names []string
+		if err := DB.Table("users").
+			Where("faculty IS NOT NULL AND TRIM(faculty) <> '' AND faculty_id IS NULL").
+			Distinct().Pluck("TRIM(faculty)", &names).Error; err != nil {
+			return fmt.Errorf("failed to read legacy faculties: %w", err)
+		}
+
+		for _, name := range names {
+			faculty := Faculty{Name: name}
+			if err := DB.Where("name = ?", name).FirstOrCreate(&faculty).Error; err != nil {
+				return fmt.Errorf("failed to create faculty %q: %w", name, err)
+			}
+			if err := DB.Table("users").Where("TRIM(faculty) = ? AND faculty_id IS NULL", name).
+				Update("faculty_id", faculty.ID).Error; err != nil {
+				return fmt.Errorf("failed to link users to faculty %q: %w", name, err)
+			}
+		}
+
+		if err := DB.Migrator().DropColumn(&User{}, "faculty"); err != nil {
+			return fmt.Errorf("failed to drop legacy faculty column: %w", err)
+		}
+		log.Printf("✅ Migrated %d legacy faculties", len(names))
+	}
+
+	// Documents belong to the faculty of their creator
+	return DB.Exec(`UPDATE documents SET faculty_id = users.faculty_id
+		FROM users WHERE documents.creator_id = users.id
+		AND documents.faculty_id IS NULL AND users.faculty_id IS NOT NULL`).Error
+}
@@ -7,11 +7,21 @@ import (
 type ActionType string
 
 const (
-	ActionCreated   ActionType = "Created"
-	ActionApproved  ActionType = "Approved"
-	ActionRejected  ActionType = "Rejected"
-	ActionDelegated ActionType = "Delegated"
-	ActionExpired   ActionType = "Expired"
+	ActionCreated      ActionType = "Created"
+	ActionApproved     ActionType = "Approved"
+	ActionRejected     ActionType = "Rejected"
+	ActionDelegated    ActionType = "Delegated"
+	ActionExpired      ActionType = "Expired"
+	ActionUnassigned   ActionType = "Unassigned"
+	ActionStepStarted  ActionType = "StepStarted"
+	ActionStepApproved ActionType = "StepApproved"
+	ActionVoteApproved ActionType = "VoteApproved"
+	ActionVoteRejected ActionType = "VoteRejected"
+	ActionEdited       ActionType = "Edited"
+	ActionResubmitted  ActionType = "Resubmitted"
+	ActionRestored     ActionType = "VersionRestored"
+	ActionAttached     ActionType = "Attached"
+	ActionDetached     ActionType = "Detached"
 )
 
 type History struct {
@@ -20,6 +30,8 @@ type History struct {
 	ActorID    uint       `gorm:"not null" json:"actor_id"`
 	Action     ActionType `gorm:"size:50;not null" json:"action"`
 	Comment    string     `gorm:"type:text" json:"comment,omitempty"`
+	StepID     *uint      `gorm:"index" json:"step_id,omitempty"`
+	Version    int        `gorm:"default:0" json:"version,omitempty"`
 	Timestamp  time.Time  `gorm:"autoCreateTime" json:"timestamp"`
 
 	// Relations
@@ -34,6 +46,8 @@ type HistoryResponse struct {
 	ActorName  string     `json:"actor_name"`
 	Action     ActionType `json:"action"`
 	Comment    string     `json:"comment,omitempty"`
+	StepID     *uint      `json:"step_id,omitempty"`
+	Version    int        `json:"version,omitempty"`
 	Timestamp  time.Time  `json:"timestamp"`
 }
 
@@ -45,6 +59,8 @@ func (h *History) ToResponse() HistoryResponse {
 		ActorName:  h.Actor.FullName,
 		Action:     h.Action,
 		Comment:    h.Comment,
+		StepID:     h.StepID,
+		Version:    h.Version,
 		Timestamp:  h.Timestamp,
 	}
 }
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// LoginThrottle tracks failed login attempts for one key: an account
+// ("account:<email>") or a client address ("ip:<addr>")
+type LoginThrottle struct {
+	Key           string     `gorm:"primaryKey;size:300" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// MFARecoveryCode is a single-use backup code for two-factor login.
+// Only the SHA-256 hash of the code is stored.
+type MFARecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"size:64;not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+)
+
+// PasswordResetToken is a single-use, time-limited token for the forgot-password flow.
+// Only the SHA-256 hash of the token is stored.
+type PasswordResetToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	TokenHash string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	// Relations
+	User User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// IsUsable reports whether the token has not been used and has not expired
+func (t *PasswordResetToken) IsUsable() bool {
+	return t.UsedAt == nil && time.Now().Before(t.ExpiresAt)
+}
+
+// EmailChangeRequest holds a pending change of a user's email address until
+// the new address is confirmed. Only the SHA-256 hash of the token is stored.
+type EmailChangeRequest struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	NewEmail    string     `gorm:"size:255;not null" json:"new_email"`
+	TokenHash   string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+
+	// Relations
+	User User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// IsUsable reports whether the request has not been confirmed and has not expired
+func (r *EmailChangeRequest) IsUsable() bool {
+	return r.ConfirmedAt == nil && time.Now().Before(r.ExpiresAt)
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BlobText is the plain text extracted from a blob for full-text search.
+// Content is empty when the file has no text.
+type BlobText struct {
+	Hash        string    `gorm:"primaryKey;size:64" json:"hash"`
+	Content     string    `gorm:"type:text;not null" json:"-"`
+	ExtractedAt time.Time `json:"extracted_at"`
+}
+
+// SaveBlobText stores the extracted text of a blob
+func SaveBlobText(hash, content string) error {
+	text := BlobText{Hash: hash, Content: content, ExtractedAt: time.Now()}
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "hash"}},
+		DoUpdates: clause.AssignmentColumns([]string{"content", "extracted_at"}),
+	}).Create(&text).Error
+}
+
+// DocumentSearch holds the full-text search vectors of a document. Internal
+// comments have their own vector because only admins may find them.
+type DocumentSearch struct {
+	DocumentID     uint   `gorm:"primaryKey"`
+	Vector         string `gorm:"type:tsvector;not null;index:idx_document_search_vector,type:gin"`
+	InternalVector string `gorm:"type:tsvector;not null;index:idx_document_search_internal_vector,type:gin"`
+}
+
+func (DocumentSearch) TableName() string {
+	return "document_search"
+}
+
+// DocumentSearchResult is a search hit with the matches highlighted. The
+// highlights are HTML-escaped and wrap matches in <mark>.
+type DocumentSearchResult struct {
+	DocumentResponse
+	Rank           float32 `json:"rank"`
+	TitleHighlight string  `json:"title_highlight"`
+	Snippet        string  `json:"snippet,omitempty"`
+}
+
+// SearchTexts is a lateral subquery with the searchable texts of each row
+// of documents: public and internal comments and the text of the current
+// file and the attachments
+const SearchTexts = `LATERAL (SELECT
+	(SELECT string_agg(body, ' ') FROM comments
+		WHERE comments.document_id = documents.id AND comments.deleted_at IS NULL AND NOT comments.is_internal) AS comments,
+	(SELECT string_agg(body, ' ') FROM comments
+		WHERE comments.document_id = documents.id AND comments.deleted_at IS NULL AND comments.is_internal) AS internal_comments,
+	(SELECT string_agg(blob_texts.content, ' ') FROM blob_texts WHERE blob_texts.hash IN (
+		SELECT checksum FROM document_attachments WHERE document_attachments.document_id = documents.id
+		UNION
+		SELECT checksum FROM document_versions
+			WHERE document_versions.document_id = documents.id AND document_versions.version = documents.current_version
+	)) AS files
+) search_texts`
+
+// SearchQuery parses a user query with both the Russian and the English
+// configuration; it takes the query text twice
+const SearchQuery = "(websearch_to_tsquery('russian', ?) || websearch_to_tsquery('english', ?))"
+
+// searchVector indexes a text in both languages with the given weight
+func searchVector(text, weight string) string {
+	return fmt.Sprintf("setweight(to_tsvector('russian', COALESCE(%[1]s, '')) || "+
+		"to_tsvector('english', COALESCE(%[1]s, '')), '%[2]s')", text, weight)
+}
+
+// RefreshSearchIndex rebuilds the search vectors of the given documents:
+// title (weight A), description (B), comments (C) and file text (D)
+func RefreshSearchIndex(tx *gorm.DB, documentIDs ...uint) error {
+	if len(documentIDs) == 0 {
+		return nil
+	}
+	return tx.Exec(`INSERT INTO document_search (document_id, vector, internal_vector)
+		SELECT documents.id, `+
+		searchVector("documents.title", "A")+" || "+
+		searchVector("documents.description", "B")+" || "+
+		searchVector("search_texts.comments", "C")+" || "+
+		searchVector("search_texts.files", "D")+", "+
+		searchVector("search_texts.internal_comments", "C")+`
+		FROM documents CROSS JOIN `+SearchTexts+`
+		WHERE documents.id IN ?
+		ON CONFLICT (document_id) DO UPDATE
+		SET vector = EXCLUDED.vector, internal_vector = EXCLUDED.internal_vector`, documentIDs).Error
+}
+
+// RefreshSearchIndexForBlob rebuilds the vectors of every document whose
+// current file or attachments have the given content
+func RefreshSearchIndexForBlob(hash string) error {
+	var ids []uint
+	if err := DB.Raw(`SELECT document_id FROM document_attachments WHERE checksum = ?
+		UNION
+		SELECT documents.id FROM documents JOIN document_versions
+			ON document_versions.document_id = documents.id AND document_versions.version = documents.current_version
+		WHERE document_versions.checksum = ?`, hash, hash).Scan(&ids).Error; err != nil {
+		return err
+	}
+	return RefreshSearchIndex(DB, ids...)
+}
+
+// BackfillSearchIndex indexes documents that have no search vectors yet,
+// e.g. those created before search existed
+func BackfillSearchIndex() error {
+	var ids []uint
+	if err := DB.Model(&Document{}).
+		Where("NOT EXISTS (SELECT 1 FROM document_search WHERE document_search.document_id = documents.id)").
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for start := 0; start < len(ids); start += 100 {
+		end := min(start+100, len(ids))
+		if err := RefreshSearchIndex(DB, ids[start:end]...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+)
+
+// Session is a server-side login session backing a rotating refresh token.
+// Access tokens carry the session ID, so revoking a session cuts off every
+// access token issued for it.
+type Session struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	UserID            uint       `gorm:"not null;index" json:"user_id"`
+	RefreshTokenHash  string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	PreviousTokenHash string     `gorm:"size:64;index" json:"-"`
+	UserAgent         string     `gorm:"size:500" json:"user_agent"`
+	IPAddress         string     `gorm:"size:64" json:"ip_address"`
+	ExpiresAt         time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt        time.Time  `json:"last_used_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+
+	// Relations
+	User User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// IsActive reports whether the session can still be used
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
+
+// RevokeSession marks a single session as revoked
+func RevokeSession(sessionID uint) error {
+	return DB.Model(&Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeUserSessions revokes every active session of a user
+func RevokeUserSessions(userID uint) error {
+	return DB.Model(&Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
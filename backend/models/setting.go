@@ -0,0 +1,80 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Setting is a runtime-configurable key/value pair managed by super-admins
+type Setting struct {
+	Key       string    `gorm:"primaryKey;size:100" json:"key"`
+	Value     string    `gorm:"type:text" json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Setting keys
+const (
+	SettingMFARequiredRoles = "mfa_required_roles"
+	SettingUploadTypes      = "upload_allowed_types"
+)
+
+// DefaultUploadExtensions are allowed until a super-admin changes the list
+var DefaultUploadExtensions = []string{
+	".pdf", ".doc", ".docx", ".xls", ".xlsx", ".ppt", ".pptx",
+	".txt", ".png", ".jpg", ".jpeg", ".gif", ".zip", ".rar",
+}
+
+// GetSettingJSON decodes a JSON setting into dest. It returns false when the
+// setting is missing or cannot be decoded, leaving dest untouched.
+func GetSettingJSON(key string, dest interface{}) bool {
+	var setting Setting
+	if err := DB.First(&setting, "key = ?", key).Error; err != nil {
+		return false
+	}
+	return json.Unmarshal([]byte(setting.Value), dest) == nil
+}
+
+// SetSettingJSON stores value as a JSON-encoded setting
+func SetSettingJSON(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return DB.Save(&Setting{Key: key, Value: string(data)}).Error
+}
+
+// MFARequiredRoles returns the roles that must use two-factor authentication
+func MFARequiredRoles() []UserRole {
+	var roles []UserRole
+	GetSettingJSON(SettingMFARequiredRoles, &roles)
+	return roles
+}
+
+// MFARequiredForRole reports whether users with the role must enroll in MFA
+func MFARequiredForRole(role UserRole) bool {
+	for _, r := range MFARequiredRoles() {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedUploadExtensions returns the file extensions users may upload
+func AllowedUploadExtensions() []string {
+	var extensions []string
+	if !GetSettingJSON(SettingUploadTypes, &extensions) {
+		return DefaultUploadExtensions
+	}
+	return extensions
+}
+
+// UploadExtensionAllowed reports whether files with the extension may be uploaded
+func UploadExtensionAllowed(ext string) bool {
+	for _, allowed := range AllowedUploadExtensions() {
+		if allowed == ext {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"time"
+)
+
+// ScanStatus is the antivirus verdict of an uploaded file
+type ScanStatus string
+
+const (
+	// ScanPending: waiting for the scanner, the file is quarantined
+	ScanPending ScanStatus = "pending"
+	// ScanClean: no threat found
+	ScanClean ScanStatus = "clean"
+	// ScanInfected: a threat was found, the file stays quarantined
+	ScanInfected ScanStatus = "infected"
+	// ScanFailed: the scanner could not be reached, the scan is retried
+	ScanFailed ScanStatus = "failed"
+	// ScanError: the scanner refused the file (e.g. over its size limit) or
+	// kept failing; the file stays quarantined until a super-admin rescans it
+	ScanError ScanStatus = "error"
+	// ScanSkipped: scanning is disabled on this server
+	ScanSkipped ScanStatus = "skipped"
+)
+
+// IsReleased reports whether files with this verdict may be downloaded
+func (s ScanStatus) IsReleased() bool {
+	return s == ScanClean || s == ScanSkipped
+}
+
+// UploadURLPrefix starts every file reference handed out by the upload
+// endpoint, e.g. "/uploads/20240101_120000_abcd1234.pdf"
+const UploadURLPrefix = "/uploads/"
+
+// Upload is a file stored through the upload endpoints. Files stay in
+// quarantine until the antivirus scan comes back clean.
+type Upload struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	StoredName    string     `gorm:"size:255;uniqueIndex;not null" json:"stored_name"`
+	OriginalName  string     `gorm:"size:255" json:"original_name"`
+	MimeType      string     `gorm:"size:255" json:"mime_type"`
+	Size          int64      `gorm:"not null" json:"size"`
+	Checksum      string     `gorm:"size:64;index" json:"checksum"`
+	UploaderID    uint       `gorm:"not null;index" json:"uploader_id"`
+	ScanStatus    ScanStatus `gorm:"size:20;not null;index" json:"scan_status"`
+	ScanSignature string     `gorm:"size:255" json:"scan_signature,omitempty"`
+	ScanAttempts  int        `gorm:"not null;default:0" json:"scan_attempts,omitempty"`
+	ScannedAt     *time.Time `json:"scanned_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+type UploadResponse struct {
+	ID            uint       `json:"id"`
+	Filename      string     `json:"filename"`
+	OriginalName  string     `json:"original_name"`
+	MimeType      string     `json:"mime_type"`
+	Size          int64      `json:"size"`
+	Checksum      string     `json:"checksum,omitempty"`
+	URL           string     `json:"url"`
+	ScanStatus    ScanStatus `json:"scan_status"`
+	ScanSignature string     `json:"scan_signature,omitempty"`
+	ScannedAt     *time.Time `json:"scanned_at,omitempty"`
+}
+
+func (u *Upload) ToResponse() UploadResponse {
+	return UploadResponse{
+		ID:            u.ID,
+		Filename:      u.StoredName,
+		OriginalName:  u.OriginalName,
+		MimeType:      u.MimeType,
+		Size:          u.Size,
+		Checksum:      u.Checksum,
+		URL:           UploadURLPrefix + u.StoredName,
+		ScanStatus:    u.ScanStatus,
+		ScanSignature: u.ScanSignature,
+		ScannedAt:     u.ScannedAt,
+	}
+}
+
+// StorageKey returns where the content is kept: the blob for deduplicated
+// uploads, the stored name for uploads from before deduplication
+func (u *Upload) StorageKey() string {
+	if u.Checksum != "" {
+		return BlobKey(u.Checksum)
+	}
+	return u.StoredName
+}
+
+// FindUpload returns the record of a stored file. Files uploaded before
+// uploads were recorded have none.
+func FindUpload(storedName string) *Upload {
+	var upload Upload
+	if err := DB.Where("stored_name = ?", storedName).First(&upload).Error; err != nil {
+		return nil
+	}
+	return &upload
+}
+
+// OrphanedUploads returns uploads created before the cutoff that no
+// document, file version or attachment refers to
+func OrphanedUploads(before time.Time) ([]Upload, error) {
+	var uploads []Upload
+	err := DB.Where("created_at < ?", before).
+		Where("NOT EXISTS (SELECT 1 FROM documents WHERE documents.file_path = ? || uploads.stored_name)", UploadURLPrefix).
+		Where("NOT EXISTS (SELECT 1 FROM document_versions WHERE document_versions.file_path = ? || uploads.stored_name)",
+			UploadURLPrefix).
+		Where("NOT EXISTS (SELECT 1 FROM document_attachments WHERE document_attachments.stored_name = uploads.stored_name)").
+		Order("created_at ASC").
+		Find(&uploads).Error
+	return uploads, err
+}
+
+// UploadReferenced reports whether a stored file is used by a document, a
+// file version or an attachment. Deleted documents count as well.
+func UploadReferenced(storedName string) (bool, error) {
+	path := UploadURLPrefix + storedName
+	var count int64
+	err := DB.Raw(`SELECT
+		(SELECT COUNT(*) FROM documents WHERE file_path = ?) +
+		(SELECT COUNT(*) FROM document_versions WHERE file_path = ?) +
+		(SELECT COUNT(*) FROM document_attachments WHERE stored_name = ?)`,
+		path, path, storedName).Scan(&count).Error
+	return count > 0, err
+}
+
+// ResumableUpload is an upload sent in chunks through the tus protocol.
+// Data is collected in a partial file until Offset reaches Length; the
+// finished file becomes a regular Upload.
+type ResumableUpload struct {
+	ID         string    `gorm:"primaryKey;size:36" json:"id"`
+	UploaderID uint      `gorm:"not null;index" json:"uploader_id"`
+	Filename   string    `gorm:"size:255;not null" json:"filename"`
+	Length     int64     `gorm:"not null" json:"length"`
+	Offset     int64     `gorm:"not null" json:"offset"`
+	UploadID   *uint     `json:"upload_id,omitempty"`
+	ExpiresAt  time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	// Relations
+	Upload *Upload `gorm:"foreignKey:UploadID" json:"-"`
+}
+
+type ResumableUploadResponse struct {
+	ID        string          `json:"id"`
+	Filename  string          `json:"filename"`
+	Length    int64           `json:"length"`
+	Offset    int64           `json:"offset"`
+	Completed bool            `json:"completed"`
+	ExpiresAt time.Time       `json:"expires_at"`
+	Upload    *UploadResponse `json:"upload,omitempty"`
+}
+
+// IsComplete reports whether every byte has been received
+func (u *ResumableUpload) IsComplete() bool {
+	return u.Offset >= u.Length
+}
+
+func (u *ResumableUpload) ToResponse() ResumableUploadResponse {
+	resp := ResumableUploadResponse{
+		ID:        u.ID,
+		Filename:  u.Filename,
+		Length:    u.Length,
+		Offset:    u.Offset,
+		Completed: u.UploadID != nil,
+		ExpiresAt: u.ExpiresAt,
+	}
+	if u.Upload != nil {
+		upload := u.Upload.ToResponse()
+		resp.Upload = &upload
+	}
+	return resp
+}
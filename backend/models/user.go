@@ -1,6 +1,9 @@
 package models
 
 import (
+	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -21,13 +24,24 @@ type User struct {
 	Password   string         `gorm:"size:255;not null" json:"-"`
 	FullName   string         `gorm:"size:255;not null" json:"full_name"`
 	Role       UserRole       `gorm:"size:50;not null;default:'student'" json:"role"`
-	Faculty    string         `gorm:"size:255" json:"faculty"`
+	FacultyID  *uint          `gorm:"index" json:"faculty_id,omitempty"`
 	IsApproved bool           `gorm:"default:false" json:"is_approved"`
 	CreatedAt  time.Time      `json:"created_at"`
 	UpdatedAt  time.Time      `json:"updated_at"`
 	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
 
+	// Lifecycle
+	IsActive        bool   `gorm:"not null;default:true" json:"is_active"`
+	RejectionReason string `gorm:"type:text" json:"rejection_reason,omitempty"`
+
+	// Two-factor authentication
+	MFAEnabled      bool   `gorm:"default:false" json:"mfa_enabled"`
+	MFASecret       string `gorm:"size:64" json:"-"`
+	MFALastUsedStep int64  `json:"-"`
+
 	// Relations
+	Faculty           *Faculty   `gorm:"foreignKey:FacultyID" json:"faculty,omitempty"`
+	ManagedFaculties  []Faculty  `gorm:"many2many:admin_faculties" json:"-"`
 	CreatedDocuments  []Document `gorm:"foreignKey:CreatorID" json:"-"`
 	AssignedDocuments []Document `gorm:"foreignKey:AssignedToID" json:"-"`
 	HistoryActions    []History  `gorm:"foreignKey:ActorID" json:"-"`
@@ -53,17 +67,74 @@ type UserResponse struct {
 	Email      string   `json:"email"`
 	FullName   string   `json:"full_name"`
 	Role       UserRole `json:"role"`
+	FacultyID  *uint    `json:"faculty_id,omitempty"`
 	Faculty    string   `json:"faculty"`
 	IsApproved bool     `json:"is_approved"`
+	IsActive   bool     `json:"is_active"`
+	MFAEnabled bool     `json:"mfa_enabled"`
+
+	RejectionReason string `json:"rejection_reason,omitempty"`
+}
+
+// IsRejected reports whether a pending admin request was rejected
+func (u *User) IsRejected() bool {
+	return !u.IsApproved && u.RejectionReason != ""
+}
+
+// IsActiveAdmin reports whether the user can currently take documents
+func (u *User) IsActiveAdmin() bool {
+	return u.IsActive && u.IsApproved && !u.DeletedAt.Valid && (u.Role == RoleAdmin || u.Role == RoleSuperAdmin)
 }
 
 func (u *User) ToResponse() UserResponse {
-	return UserResponse{
+	resp := UserResponse{
 		ID:         u.ID,
 		Email:      u.Email,
 		FullName:   u.FullName,
 		Role:       u.Role,
-		Faculty:    u.Faculty,
+		FacultyID:  u.FacultyID,
 		IsApproved: u.IsApproved,
+		IsActive:   u.IsActive,
+		MFAEnabled: u.MFAEnabled,
+
+		RejectionReason: u.RejectionReason,
+	}
+
+	if u.Faculty != nil {
+		resp.Faculty = u.Faculty.Name
 	}
+
+	return resp
+}
+
+// FacultyScope returns the faculties whose document queue an admin works on:
+// their own faculty, the faculties assigned to them and all sub-departments.
+// ManagedFaculties must be preloaded.
+func (u *User) FacultyScope() []uint {
+	var ids []uint
+	if u.FacultyID != nil {
+		ids = append(ids, *u.FacultyID)
+	}
+	for _, f := range u.ManagedFaculties {
+		ids = append(ids, f.ID)
+	}
+	return ExpandFacultyIDs(ids)
+}
+
+// MigrateEmailIndex backs the case-insensitive email checks with a unique
+// index on LOWER(email). It is skipped with a warning while existing
+// addresses still differ only in case.
+func MigrateEmailIndex() error {
+	var duplicates []string
+	if err := DB.Unscoped().Model(&User{}).Group("LOWER(email)").Having("COUNT(*) > 1").
+		Pluck("LOWER(email)", &duplicates).Error; err != nil {
+		return fmt.Errorf("failed to check duplicate emails: %w", err)
+	}
+	if len(duplicates) > 0 {
+		log.Printf("⚠️  Case-insensitive email index not created, duplicate addresses: %s",
+			strings.Join(duplicates, ", "))
+		return nil
+	}
+
+	return DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (LOWER(email))").Error
 }
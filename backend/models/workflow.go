@@ -0,0 +1,207 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ApprovalMode defines who has to sign off a workflow step
+type ApprovalMode string
+
+const (
+	// ApprovalSingle: any one user matching the step restrictions decides
+	ApprovalSingle ApprovalMode = "single"
+	// ApprovalAll: every listed approver must approve
+	ApprovalAll ApprovalMode = "all"
+	// ApprovalAny: one approval from the listed approvers is enough
+	ApprovalAny ApprovalMode = "any"
+	// ApprovalQuorum: RequiredApprovals of the listed approvers must approve
+	ApprovalQuorum ApprovalMode = "quorum"
+)
+
+// WorkflowDefinition is an ordered chain of approval steps a document goes
+// through, e.g. dean's office → department head → rector
+type WorkflowDefinition struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	Name        string         `gorm:"uniqueIndex;size:255;not null" json:"name"`
+	Description string         `gorm:"type:text" json:"description"`
+	IsActive    bool           `gorm:"not null" json:"is_active"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Relations
+	Steps []WorkflowStep `gorm:"foreignKey:WorkflowID" json:"steps"`
+}
+
+// WorkflowStep is one approval stage. The approver is restricted by any
+// combination of role, faculty and a specific user.
+type WorkflowStep struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	WorkflowID        uint      `gorm:"not null;index" json:"workflow_id"`
+	Position          int       `gorm:"not null" json:"position"`
+	Name              string    `gorm:"size:255;not null" json:"name"`
+	ApproverRole      UserRole  `gorm:"size:50" json:"approver_role,omitempty"`
+	ApproverFacultyID *uint     `json:"approver_faculty_id,omitempty"`
+	ApproverUserID    *uint     `json:"approver_user_id,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+
+	// Parallel sign-off by several listed approvers
+	ApprovalMode      ApprovalMode `gorm:"size:20;not null;default:'single'" json:"approval_mode"`
+	RequiredApprovals int          `gorm:"default:0" json:"required_approvals,omitempty"`
+
+	// Relations
+	ApproverFaculty *Faculty `gorm:"foreignKey:ApproverFacultyID" json:"-"`
+	ApproverUser    *User    `gorm:"foreignKey:ApproverUserID" json:"-"`
+	Approvers       []User   `gorm:"many2many:workflow_step_approvers" json:"-"`
+}
+
+// IsParallel reports whether the step collects votes from listed approvers
+func (s *WorkflowStep) IsParallel() bool {
+	return s.ApprovalMode == ApprovalAll || s.ApprovalMode == ApprovalAny || s.ApprovalMode == ApprovalQuorum
+}
+
+// Quorum returns how many approvals a parallel step needs
+func (s *WorkflowStep) Quorum() int {
+	switch s.ApprovalMode {
+	case ApprovalAll:
+		return len(s.Approvers)
+	case ApprovalAny:
+		return 1
+	case ApprovalQuorum:
+		return s.RequiredApprovals
+	}
+	return 1
+}
+
+type WorkflowStepResponse struct {
+	ID                  uint     `json:"id"`
+	Position            int      `json:"position"`
+	Name                string   `json:"name"`
+	ApproverRole        UserRole `json:"approver_role,omitempty"`
+	ApproverFacultyID   *uint    `json:"approver_faculty_id,omitempty"`
+	ApproverFacultyName string   `json:"approver_faculty_name,omitempty"`
+	ApproverUserID      *uint    `json:"approver_user_id,omitempty"`
+	ApproverUserName    string   `json:"approver_user_name,omitempty"`
+
+	ApprovalMode      ApprovalMode   `json:"approval_mode"`
+	RequiredApprovals int            `json:"required_approvals,omitempty"`
+	Approvers         []UserResponse `json:"approvers,omitempty"`
+}
+
+type WorkflowResponse struct {
+	ID          uint                   `json:"id"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	IsActive    bool                   `json:"is_active"`
+	Steps       []WorkflowStepResponse `json:"steps"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+}
+
+func (s *WorkflowStep) ToResponse() WorkflowStepResponse {
+	resp := WorkflowStepResponse{
+		ID:                s.ID,
+		Position:          s.Position,
+		Name:              s.Name,
+		ApproverRole:      s.ApproverRole,
+		ApproverFacultyID: s.ApproverFacultyID,
+		ApproverUserID:    s.ApproverUserID,
+
+		ApprovalMode: s.ApprovalMode,
+	}
+
+	if s.IsParallel() {
+		resp.RequiredApprovals = s.Quorum()
+		for _, approver := range s.Approvers {
+			resp.Approvers = append(resp.Approvers, approver.ToResponse())
+		}
+	}
+
+	if s.ApproverFaculty != nil {
+		resp.ApproverFacultyName = s.ApproverFaculty.Name
+	}
+
+	if s.ApproverUser != nil {
+		resp.ApproverUserName = s.ApproverUser.FullName
+	}
+
+	return resp
+}
+
+func (w *WorkflowDefinition) ToResponse() WorkflowResponse {
+	resp := WorkflowResponse{
+		ID:          w.ID,
+		Name:        w.Name,
+		Description: w.Description,
+		IsActive:    w.IsActive,
+		Steps:       []WorkflowStepResponse{},
+		CreatedAt:   w.CreatedAt,
+		UpdatedAt:   w.UpdatedAt,
+	}
+
+	for _, step := range w.Steps {
+		resp.Steps = append(resp.Steps, step.ToResponse())
+	}
+
+	return resp
+}
+
+// PreloadWorkflowSteps loads steps in order together with their approver details
+func PreloadWorkflowSteps(db *gorm.DB) *gorm.DB {
+	return db.Preload("Steps", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position ASC")
+	}).Preload("Steps.ApproverFaculty").Preload("Steps.ApproverUser").Preload("Steps.Approvers")
+}
+
+// CanApprove reports whether the user satisfies every restriction of the step.
+// user.ManagedFaculties must be preloaded for faculty-restricted steps.
+func (s *WorkflowStep) CanApprove(user *User) bool {
+	if s.IsParallel() {
+		for _, approver := range s.Approvers {
+			if approver.ID == user.ID {
+				return true
+			}
+		}
+		return false
+	}
+
+	if s.ApproverUserID != nil && *s.ApproverUserID != user.ID {
+		return false
+	}
+
+	if s.ApproverRole != "" && s.ApproverRole != user.Role {
+		return false
+	}
+
+	if s.ApproverFacultyID != nil {
+		for _, id := range user.FacultyScope() {
+			if id == *s.ApproverFacultyID {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// ActionableStepDocumentIDs selects pending documents whose current workflow
+// step the user is allowed to approve, for use in admin queues
+func ActionableStepDocumentIDs(user *User) *gorm.DB {
+	single := DB.Where("workflow_steps.approval_mode NOT IN ?", []ApprovalMode{ApprovalAll, ApprovalAny, ApprovalQuorum}).
+		Where("workflow_steps.approver_user_id IS NULL OR workflow_steps.approver_user_id = ?", user.ID).
+		Where("workflow_steps.approver_role = '' OR workflow_steps.approver_role IS NULL OR workflow_steps.approver_role = ?", user.Role).
+		Where("workflow_steps.approver_faculty_id IS NULL OR workflow_steps.approver_faculty_id IN ?", user.FacultyScope())
+
+	listed := DB.Where("workflow_steps.approval_mode IN ?", []ApprovalMode{ApprovalAll, ApprovalAny, ApprovalQuorum}).
+		Where("workflow_steps.id IN (?)", DB.Table("workflow_step_approvers").
+			Select("workflow_step_id").Where("user_id = ?", user.ID))
+
+	return DB.Table("documents").
+		Select("documents.id").
+		Joins("JOIN workflow_steps ON workflow_steps.workflow_id = documents.workflow_id AND workflow_steps.position = documents.current_step").
+		Where("documents.status = ? AND documents.deleted_at IS NULL", StatusPending).
+		Where(single.Or(listed))
+}
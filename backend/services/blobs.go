@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+
+	"synergy_dms/models"
+	"synergy_dms/storage"
+
+	"gorm.io/gorm"
+)
+
+// Blobs stores upload content once per SHA-256 hash so identical files
+// share one copy
+type Blobs struct {
+	Storage storage.Storage
+}
+
+func NewBlobs(store storage.Storage) *Blobs {
+	return &Blobs{Storage: store}
+}
+
+// HashContent streams the content through SHA-256
+func HashContent(r io.Reader) (string, int64, error) {
+	hash := sha256.New()
+	size, err := io.Copy(hash, r)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// Store records a new upload whose Checksum and Size are set. The content
+// is written only if no healthy blob with the same hash exists yet. The blob
+// row is locked while deciding and writing, so a concurrent Remove can't
+// delete the content in between.
+func (b *Blobs) Store(ctx context.Context, src io.Reader, upload *models.Upload) error {
+	return models.DB.Transaction(func(tx *gorm.DB) error {
+		write, err := models.AcquireBlob(tx, upload.Checksum, upload.Size, upload.MimeType)
+		if err != nil {
+			return err
+		}
+		if write {
+			if err := b.Storage.Put(ctx, models.BlobKey(upload.Checksum), src, upload.Size, upload.MimeType); err != nil {
+				return err
+			}
+		}
+		return tx.Create(upload).Error
+	})
+}
+
+// Remove deletes an upload by its stored name. The content goes away with
+// the last upload that refers to it.
+func (b *Blobs) Remove(ctx context.Context, storedName string) error {
+	var upload models.Upload
+	if err := models.DB.Where("stored_name = ?", storedName).First(&upload).Error; err != nil {
+		// Files from before uploads were recorded
+		return b.Storage.Delete(ctx, storedName)
+	}
+
+	// Uploads from before deduplication own their content
+	orphaned := upload.Checksum == ""
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.ResumableUpload{}).Where("upload_id = ?", upload.ID).
+			Update("upload_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&upload).Error; err != nil {
+			return err
+		}
+		if upload.Checksum == "" {
+			return nil
+		}
+		var err error
+		orphaned, err = models.ReleaseBlob(tx, upload.Checksum)
+		if err != nil || !orphaned {
+			return err
+		}
+		if err := tx.Delete(&models.BlobText{}, "hash = ?", upload.Checksum).Error; err != nil {
+			return err
+		}
+		// Delete the content while the blob row is still locked: a Store of
+		// the same hash waits and then writes it again
+		if err := b.Storage.Delete(ctx, upload.StorageKey()); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+		return nil
+	})
+	if err != nil || !orphaned {
+		return err
+	}
+	if upload.Checksum != "" {
+		b.removePreviews(ctx, upload.Checksum)
+		return nil
+	}
+	return b.Storage.Delete(ctx, upload.StorageKey())
+}
+
+// removePreviews deletes the rendered previews of a blob. Leftovers are
+// picked up by the orphan cleanup.
+func (b *Blobs) removePreviews(ctx context.Context, hash string) {
+	for _, size := range []string{models.PreviewSizeThumbnail, models.PreviewSizeLarge} {
+		if err := b.Storage.Delete(ctx, models.PreviewKey(hash, size)); err != nil &&
+			!errors.Is(err, storage.ErrNotFound) {
+			log.Printf("⚠️  Failed to delete preview of %s: %v", hash, err)
+		}
+	}
+}
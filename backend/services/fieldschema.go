@@ -0,0 +1,88 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"synergy_dms/models"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Custom fields of documents are checked against the JSON Schema of their
+// document type. Schemas default to draft 2020-12 and "format" is enforced,
+// so a "date" field must hold an ISO date.
+
+const fieldSchemaURL = "mem:///document-type.json"
+
+var (
+	ErrSchemaNotObject = errors.New("schema must describe an object (\"type\": \"object\")")
+	errSchemaRemoteRef = errors.New("schemas cannot reference other documents")
+)
+
+// CompileFieldSchema compiles the schema of a document type. Only
+// self-contained schemas are accepted: $ref may point inside the schema but
+// not to files or URLs.
+func CompileFieldSchema(schema models.JSONMap) (*jsonschema.Schema, error) {
+	if schema["type"] != "object" {
+		return nil, ErrSchemaNotObject
+	}
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	compiler.AssertFormat = true
+	compiler.LoadURL = func(string) (io.ReadCloser, error) {
+		return nil, errSchemaRemoteRef
+	}
+	if err := compiler.AddResource(fieldSchemaURL, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return compiler.Compile(fieldSchemaURL)
+}
+
+// ValidateFields checks the custom fields of a document against its type.
+// It returns one message per problem, or nil when the fields are valid.
+func ValidateFields(docType *models.DocumentType, fields models.JSONMap) ([]string, error) {
+	schema, err := CompileFieldSchema(docType.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("document type %d has an invalid schema: %w", docType.ID, err)
+	}
+	if fields == nil {
+		fields = models.JSONMap{}
+	}
+
+	var ve *jsonschema.ValidationError
+	err = schema.Validate(map[string]interface{}(fields))
+	if errors.As(err, &ve) {
+		var problems []string
+		collectProblems(ve, &problems)
+		sort.Strings(problems)
+		return problems, nil
+	}
+	return nil, err
+}
+
+// collectProblems flattens a validation error into "field: message" lines
+func collectProblems(ve *jsonschema.ValidationError, problems *[]string) {
+	if len(ve.Causes) > 0 {
+		for _, cause := range ve.Causes {
+			collectProblems(cause, problems)
+		}
+		return
+	}
+	field := strings.ReplaceAll(strings.TrimPrefix(ve.InstanceLocation, "/"), "/", ".")
+	if field == "" {
+		*problems = append(*problems, ve.Message)
+		return
+	}
+	*problems = append(*problems, field+": "+ve.Message)
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+)
+
+var (
+	// ErrContentMismatch means the file content is not what its extension claims
+	ErrContentMismatch = errors.New("file content does not match its extension")
+	// ErrMalformedDocument means a container format is structurally broken
+	ErrMalformedDocument = errors.New("malformed document")
+)
+
+// Limits for inspecting zip containers
+const (
+	maxZipEntries      = 10000
+	maxContentTypesXML = 1 << 20
+)
+
+// FileType is an upload type the server can recognise from its content
+type FileType struct {
+	Extension string `json:"extension"`
+	MimeType  string `json:"mime_type"`
+	check     func(head []byte, r io.ReaderAt, size int64) error
+}
+
+// ooxml checks that a zip container holds an Office Open XML document of the
+// given kind: the content types part must declare mainType for mainPart
+func ooxml(mainPart, mainType string) func([]byte, io.ReaderAt, int64) error {
+	return func(head []byte, r io.ReaderAt, size int64) error {
+		if !bytes.HasPrefix(head, []byte("PK\x03\x04")) {
+			return ErrContentMismatch
+		}
+
+		zr, err := zip.NewReader(r, size)
+		if err != nil || len(zr.File) > maxZipEntries {
+			return ErrMalformedDocument
+		}
+
+		var contentTypes, main *zip.File
+		for _, f := range zr.File {
+			switch f.Name {
+			case "[Content_Types].xml":
+				contentTypes = f
+			case mainPart:
+				main = f
+			}
+		}
+		if contentTypes == nil || main == nil || contentTypes.UncompressedSize64 > maxContentTypesXML {
+			return ErrMalformedDocument
+		}
+
+		rc, err := contentTypes.Open()
+		if err != nil {
+			return ErrMalformedDocument
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(io.LimitReader(rc, maxContentTypesXML))
+		if err != nil || !bytes.Contains(data, []byte(mainType)) {
+			return ErrMalformedDocument
+		}
+		return nil
+	}
+}
+
+// magic accepts files that start with one of the given signatures
+func magic(signatures ...string) func([]byte, io.ReaderAt, int64) error {
+	return func(head []byte, r io.ReaderAt, size int64) error {
+		for _, sig := range signatures {
+			if bytes.HasPrefix(head, []byte(sig)) {
+				return nil
+			}
+		}
+		return ErrContentMismatch
+	}
+}
+
+// plainZip accepts any readable zip archive
+func plainZip(head []byte, r io.ReaderAt, size int64) error {
+	if !bytes.HasPrefix(head, []byte("PK\x03\x04")) && !bytes.HasPrefix(head, []byte("PK\x05\x06")) {
+		return ErrContentMismatch
+	}
+	zr, err := zip.NewReader(r, size)
+	if err != nil || len(zr.File) > maxZipEntries {
+		return ErrMalformedDocument
+	}
+	return nil
+}
+
+// plainText accepts text without control characters. Legacy 8-bit encodings
+// such as Windows-1251 are allowed.
+func plainText(head []byte, r io.ReaderAt, size int64) error {
+	for _, b := range head {
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f' && b != 0x1b {
+			return ErrContentMismatch
+		}
+	}
+	return nil
+}
+
+const oleSignature = "\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1"
+
+// KnownFileTypes lists every type that can be allowed for upload
+var KnownFileTypes = []FileType{
+	{".pdf", "application/pdf", magic("%PDF-")},
+	{".doc", "application/msword", magic(oleSignature)},
+	{".docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		ooxml("word/document.xml", "application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml")},
+	{".xls", "application/vnd.ms-excel", magic(oleSignature)},
+	{".xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		ooxml("xl/workbook.xml", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml")},
+	{".ppt", "application/vnd.ms-powerpoint", magic(oleSignature)},
+	{".pptx", "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+		ooxml("ppt/presentation.xml", "application/vnd.openxmlformats-officedocument.presentationml.presentation.main+xml")},
+	{".txt", "text/plain", plainText},
+	{".png", "image/png", magic("\x89PNG\r\n\x1a\n")},
+	{".jpg", "image/jpeg", magic("\xFF\xD8\xFF")},
+	{".jpeg", "image/jpeg", magic("\xFF\xD8\xFF")},
+	{".gif", "image/gif", magic("GIF87a", "GIF89a")},
+	{".zip", "application/zip", plainZip},
+	{".rar", "application/vnd.rar", magic("Rar!\x1a\x07\x00", "Rar!\x1a\x07\x01\x00")},
+}
+
+// LookupFileType returns the known type for an extension such as ".pdf"
+func LookupFileType(ext string) (FileType, bool) {
+	ext = strings.ToLower(ext)
+	for _, t := range KnownFileTypes {
+		if t.Extension == ext {
+			return t, true
+		}
+	}
+	return FileType{}, false
+}
+
+// DetectFileType checks the content of a file against the type its extension
+// claims and returns the MIME type to store
+func DetectFileType(r io.ReaderAt, size int64, ext string) (string, error) {
+	fileType, ok := LookupFileType(ext)
+	if !ok {
+		return "", ErrContentMismatch
+	}
+
+	head := make([]byte, 8192)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	head = head[:n]
+
+	if err := fileType.check(head, r, size); err != nil {
+		return "", err
+	}
+	return fileType.MimeType, nil
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"synergy_dms/models"
+	"synergy_dms/storage"
+)
+
+// TextIndexer extracts the text of uploaded PDF, DOCX, XLSX and TXT files
+// in the background and refreshes the search index of the documents that use
+// them. Text belongs to the blob, so identical files are read once.
+type TextIndexer struct {
+	Storage storage.Storage
+	Workers int
+
+	queue  chan string
+	ticker *time.Ticker
+	done   chan bool
+}
+
+func NewTextIndexer(store storage.Storage, workers int) *TextIndexer {
+	if workers < 1 {
+		workers = 1
+	}
+	return &TextIndexer{
+		Storage: store,
+		Workers: workers,
+		queue:   make(chan string, 1000),
+		done:    make(chan bool),
+	}
+}
+
+// Enqueue schedules the text extraction of a blob. When the queue is full
+// the periodic retry picks it up later.
+func (s *TextIndexer) Enqueue(hash string) {
+	if hash == "" {
+		return
+	}
+	select {
+	case s.queue <- hash:
+	default:
+	}
+}
+
+// Start indexes documents created before search existed, then launches the
+// workers and the retry loop
+func (s *TextIndexer) Start() {
+	if err := models.BackfillSearchIndex(); err != nil {
+		log.Printf("❌ Failed to backfill search index: %v", err)
+	}
+
+	for i := 0; i < s.Workers; i++ {
+		go func() {
+			for hash := range s.queue {
+				s.index(hash)
+			}
+		}()
+	}
+
+	s.ticker = time.NewTicker(5 * time.Minute)
+	go func() {
+		// Pick up files left unread by a restart
+		s.requeue()
+
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-s.ticker.C:
+				s.requeue()
+			}
+		}
+	}()
+
+	log.Printf("🔤 Text indexer started (%d workers)", s.Workers)
+}
+
+// Stop halts the retry loop. Extractions in progress finish on their own.
+func (s *TextIndexer) Stop() {
+	if s.ticker != nil {
+		s.ticker.Stop()
+	}
+	s.done <- true
+	log.Println("🔤 Text indexer stopped")
+}
+
+// releasedBlob matches blobs with no upload in quarantine. Text of files
+// still being scanned or infected must not show up in search snippets.
+const releasedBlob = "NOT EXISTS (SELECT 1 FROM uploads WHERE uploads.checksum = blobs.hash AND uploads.scan_status NOT IN ?)"
+
+var releasedStatuses = []models.ScanStatus{models.ScanClean, models.ScanSkipped}
+
+// requeue schedules every supported blob whose text was not extracted yet
+func (s *TextIndexer) requeue() {
+	var hashes []string
+	models.DB.Model(&models.Blob{}).
+		Where("mime_type IN ?", textExtractableTypes).
+		Where("NOT EXISTS (SELECT 1 FROM blob_texts WHERE blob_texts.hash = blobs.hash)").
+		Where(releasedBlob, releasedStatuses).
+		Pluck("hash", &hashes)
+	for _, hash := range hashes {
+		s.Enqueue(hash)
+	}
+}
+
+func (s *TextIndexer) index(hash string) {
+	// Files in quarantine are picked up by the periodic loop once released
+	var blob models.Blob
+	if err := models.DB.Where(releasedBlob, releasedStatuses).
+		First(&blob, "hash = ?", hash).Error; err != nil {
+		return
+	}
+	if !textExtractable(blob.MimeType) {
+		return
+	}
+	var count int64
+	if models.DB.Model(&models.BlobText{}).Where("hash = ?", hash).Count(&count); count > 0 {
+		return
+	}
+
+	text, err := s.extract(&blob)
+	if err != nil {
+		var se storageError
+		if errors.As(err, &se) {
+			// Retried by the periodic loop
+			log.Printf("⚠️  Could not read %s for indexing: %v", hash, err)
+			return
+		}
+		log.Printf("❌ Failed to extract text of %s: %v", hash, err)
+	}
+
+	if err := models.SaveBlobText(hash, text); err != nil {
+		log.Printf("❌ Failed to save text of %s: %v", hash, err)
+		return
+	}
+	if err := models.RefreshSearchIndexForBlob(hash); err != nil {
+		log.Printf("❌ Failed to refresh search index for %s: %v", hash, err)
+	}
+}
+
+// extract reads the blob and returns its text. Files too large to read
+// are indexed without text.
+func (s *TextIndexer) extract(blob *models.Blob) (string, error) {
+	if blob.Size > maxIndexedFileSize {
+		return "", nil
+	}
+
+	r, _, err := s.Storage.Get(context.Background(), blob.Key())
+	if errors.Is(err, storage.ErrNotFound) {
+		return "", err
+	}
+	if err != nil {
+		return "", storageError{err}
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(io.LimitReader(r, maxIndexedFileSize))
+	if err != nil {
+		return "", storageError{err}
+	}
+	return ExtractText(data, blob.MimeType)
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"synergy_dms/models"
+	"synergy_dms/storage"
+
+	"gorm.io/gorm"
+)
+
+// IntegrityReport summarizes one verification run
+type IntegrityReport struct {
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Checked    int        `json:"checked"`
+	Missing    int        `json:"missing"`
+	Corrupted  int        `json:"corrupted"`
+}
+
+// IntegrityVerifier periodically re-reads every blob and compares it with
+// its hash, marking blobs that are missing or corrupted
+type IntegrityVerifier struct {
+	Storage  storage.Storage
+	Interval time.Duration
+
+	mu      sync.Mutex
+	running bool
+	last    *IntegrityReport
+	ticker  *time.Ticker
+	done    chan bool
+}
+
+func NewIntegrityVerifier(store storage.Storage, interval time.Duration) *IntegrityVerifier {
+	return &IntegrityVerifier{
+		Storage:  store,
+		Interval: interval,
+		done:     make(chan bool),
+	}
+}
+
+// Start launches the periodic verification
+func (v *IntegrityVerifier) Start() {
+	v.ticker = time.NewTicker(v.Interval)
+
+	go func() {
+		for {
+			select {
+			case <-v.done:
+				return
+			case <-v.ticker.C:
+				v.Run()
+			}
+		}
+	}()
+
+	log.Printf("🔎 Integrity verifier started (checking every %s)", v.Interval)
+}
+
+// Stop halts the periodic verification
+func (v *IntegrityVerifier) Stop() {
+	if v.ticker != nil {
+		v.ticker.Stop()
+	}
+	v.done <- true
+	log.Println("🔎 Integrity verifier stopped")
+}
+
+// Running reports whether a verification is in progress
+func (v *IntegrityVerifier) Running() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.running
+}
+
+// LastReport returns the summary of the latest run, nil before the first
+func (v *IntegrityVerifier) LastReport() *IntegrityReport {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.last == nil {
+		return nil
+	}
+	report := *v.last
+	return &report
+}
+
+// Run verifies every blob. It returns false if a run is already in progress.
+func (v *IntegrityVerifier) Run() bool {
+	v.mu.Lock()
+	if v.running {
+		v.mu.Unlock()
+		return false
+	}
+	v.running = true
+	report := &IntegrityReport{StartedAt: time.Now()}
+	v.last = report
+	v.mu.Unlock()
+
+	defer func() {
+		v.mu.Lock()
+		now := time.Now()
+		report.FinishedAt = &now
+		v.running = false
+		v.mu.Unlock()
+	}()
+
+	var blobs []models.Blob
+	err := models.DB.FindInBatches(&blobs, 100, func(tx *gorm.DB, batch int) error {
+		for i := range blobs {
+			status, ok := v.verify(&blobs[i])
+			if !ok {
+				continue
+			}
+
+			v.mu.Lock()
+			report.Checked++
+			switch status {
+			case models.BlobMissing:
+				report.Missing++
+			case models.BlobCorrupted:
+				report.Corrupted++
+			}
+			v.mu.Unlock()
+		}
+		return nil
+	}).Error
+	if err != nil {
+		log.Printf("❌ Integrity check failed: %v", err)
+		return true
+	}
+
+	log.Printf("🔎 Integrity check: %d blobs, %d missing, %d corrupted",
+		report.Checked, report.Missing, report.Corrupted)
+	return true
+}
+
+// verify re-hashes one blob and records the result. It returns false when
+// the blob could not be checked, e.g. because the storage is unreachable.
+func (v *IntegrityVerifier) verify(blob *models.Blob) (models.BlobStatus, bool) {
+	status, err := v.check(blob)
+	if err != nil {
+		log.Printf("⚠️  Could not verify blob %s: %v", blob.Hash, err)
+		return "", false
+	}
+
+	if status != models.BlobOK && status != blob.Status {
+		log.Printf("❌ Blob %s is %s", blob.Hash, status)
+		models.RecordAudit(models.AuditBlobDamaged, nil, nil, "",
+			fmt.Sprintf("%s is %s (%d references)", blob.Hash, status, blob.RefCount))
+	}
+
+	now := time.Now()
+	models.DB.Model(blob).UpdateColumns(map[string]interface{}{
+		"status":      status,
+		"verified_at": &now,
+	})
+	return status, true
+}
+
+func (v *IntegrityVerifier) check(blob *models.Blob) (models.BlobStatus, error) {
+	r, _, err := v.Storage.Get(context.Background(), blob.Key())
+	if errors.Is(err, storage.ErrNotFound) {
+		return models.BlobMissing, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	hash, size, err := HashContent(r)
+	if err != nil {
+		return "", err
+	}
+	if hash != blob.Hash || size != blob.Size {
+		return models.BlobCorrupted, nil
+	}
+	return models.BlobOK, nil
+}
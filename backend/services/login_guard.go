@@ -0,0 +1,166 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"synergy_dms/config"
+	"synergy_dms/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginGuard throttles password guessing per account and per client IP.
+// Every failure doubles the delay before the next attempt is allowed; after
+// the threshold is reached the key is locked for the lockout duration.
+type LoginGuard struct {
+	MaxAttempts      int
+	MaxAttemptsPerIP int
+	LockoutDuration  time.Duration
+	BackoffBase      time.Duration
+}
+
+func NewLoginGuard(cfg *config.Config) *LoginGuard {
+	return &LoginGuard{
+		MaxAttempts:      cfg.LoginMaxAttempts,
+		MaxAttemptsPerIP: cfg.LoginMaxAttemptsPerIP,
+		LockoutDuration:  cfg.LoginLockoutDuration,
+		BackoffBase:      cfg.LoginBackoffBase,
+	}
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check returns how long the caller must wait before another attempt for
+// this email/IP pair is allowed. Zero means the attempt may proceed.
+func (g *LoginGuard) Check(email, ip string) time.Duration {
+	now := time.Now()
+	wait := time.Duration(0)
+
+	for _, key := range []string{accountKey(email), ipKey(ip)} {
+		var throttle models.LoginThrottle
+		if err := models.DB.First(&throttle, "key = ?", key).Error; err != nil {
+			continue
+		}
+		if d := g.retryAfter(&throttle, now); d > wait {
+			wait = d
+		}
+	}
+
+	return wait
+}
+
+func (g *LoginGuard) retryAfter(t *models.LoginThrottle, now time.Time) time.Duration {
+	if t.LockedUntil != nil {
+		if now.Before(*t.LockedUntil) {
+			return t.LockedUntil.Sub(now)
+		}
+		return 0
+	}
+
+	if t.Failures == 0 {
+		return 0
+	}
+
+	next := t.LastFailureAt.Add(g.backoff(t.Failures))
+	if now.Before(next) {
+		return next.Sub(now)
+	}
+	return 0
+}
+
+// backoff returns base * 2^(failures-1), capped at the lockout duration
+func (g *LoginGuard) backoff(failures int) time.Duration {
+	delay := g.BackoffBase
+	for i := 1; i < failures && delay < g.LockoutDuration; i++ {
+		delay *= 2
+	}
+	if delay > g.LockoutDuration {
+		delay = g.LockoutDuration
+	}
+	return delay
+}
+
+// RecordFailure counts a failed attempt for the account and the IP, locking
+// either of them once its threshold is reached. userID is nil for unknown emails.
+func (g *LoginGuard) RecordFailure(email, ip string, userID *uint) {
+	if g.recordFailure(accountKey(email), g.MaxAttempts) {
+		models.RecordAudit(models.AuditAccountLocked, nil, userID, ip,
+			fmt.Sprintf("Account %s locked for %s after %d failed login attempts", email, g.LockoutDuration, g.MaxAttempts))
+		log.Printf("🔒 Account %s locked after %d failed login attempts", email, g.MaxAttempts)
+	}
+
+	if g.recordFailure(ipKey(ip), g.MaxAttemptsPerIP) {
+		models.RecordAudit(models.AuditIPLocked, nil, nil, ip,
+			fmt.Sprintf("IP %s locked for %s after %d failed login attempts", ip, g.LockoutDuration, g.MaxAttemptsPerIP))
+		log.Printf("🔒 IP %s locked after %d failed login attempts", ip, g.MaxAttemptsPerIP)
+	}
+}
+
+// recordFailure increments the counter for key and reports whether it just
+// got locked. The counter is bumped in a single upsert so concurrent
+// failures can't overwrite each other's counts.
+func (g *LoginGuard) recordFailure(key string, threshold int) bool {
+	now := time.Now()
+
+	// Start over once a lockout has expired or the last failure is long gone
+	expired := gorm.Expr("(login_throttles.locked_until IS NOT NULL AND login_throttles.locked_until < ?) OR "+
+		"login_throttles.last_failure_at < ?", now, now.Add(-g.LockoutDuration))
+
+	throttle := models.LoginThrottle{Key: key, Failures: 1, LastFailureAt: now}
+	err := models.DB.Clauses(
+		clause.OnConflict{
+			Columns: []clause.Column{{Name: "key"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"failures":        gorm.Expr("CASE WHEN ? THEN 1 ELSE login_throttles.failures + 1 END", expired),
+				"locked_until":    gorm.Expr("CASE WHEN ? THEN NULL ELSE login_throttles.locked_until END", expired),
+				"last_failure_at": now,
+			}),
+		},
+		clause.Returning{Columns: []clause.Column{{Name: "failures"}, {Name: "locked_until"}}},
+	).Create(&throttle).Error
+	if err != nil {
+		log.Printf("❌ Failed to record login failure for %s: %v", key, err)
+		return false
+	}
+
+	if threshold <= 0 || throttle.Failures < threshold || throttle.LockedUntil != nil {
+		return false
+	}
+
+	// Only the request that sets the lock reports it
+	result := models.DB.Model(&models.LoginThrottle{}).
+		Where("key = ? AND locked_until IS NULL", key).
+		Update("locked_until", now.Add(g.LockoutDuration))
+	if result.Error != nil {
+		log.Printf("❌ Failed to lock %s: %v", key, result.Error)
+		return false
+	}
+	return result.RowsAffected > 0
+}
+
+// RecordSuccess clears the failure counter of the account. The IP counter is
+// kept so one valid account cannot be used to reset a guessing run from that IP.
+func (g *LoginGuard) RecordSuccess(email string) {
+	models.DB.Delete(&models.LoginThrottle{}, "key = ?", accountKey(email))
+}
+
+// Unlock clears the lockout of an account (Super-Admin action)
+func (g *LoginGuard) Unlock(user *models.User, actorID uint, ip string) error {
+	if err := models.DB.Delete(&models.LoginThrottle{}, "key = ?", accountKey(user.Email)).Error; err != nil {
+		return err
+	}
+
+	models.RecordAudit(models.AuditAccountUnlocked, &actorID, &user.ID, ip,
+		fmt.Sprintf("Account %s unlocked", user.Email))
+	return nil
+}
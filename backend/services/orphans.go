@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"synergy_dms/models"
+	"synergy_dms/storage"
+)
+
+// Kinds of orphaned files
+const (
+	// OrphanUpload: a recorded upload nothing refers to
+	OrphanUpload = "upload"
+	// OrphanLegacy: a file from before uploads were recorded
+	OrphanLegacy = "legacy"
+	// OrphanBlob: stored content without a blob record, e.g. after a failed upload
+	OrphanBlob = "blob"
+	// OrphanPreview: a rendered preview whose blob is gone
+	OrphanPreview = "preview"
+)
+
+// OrphanFile is a stored file that no document uses
+type OrphanFile struct {
+	Kind       string    `json:"kind"`
+	Key        string    `json:"key"`
+	Name       string    `json:"name,omitempty"`
+	Size       int64     `json:"size"`
+	UploadedAt time.Time `json:"uploaded_at"`
+	UploaderID uint      `json:"uploader_id,omitempty"`
+}
+
+// OrphanReport is the result of one collection run
+type OrphanReport struct {
+	DryRun       bool         `json:"dry_run"`
+	Cutoff       time.Time    `json:"cutoff"`
+	StartedAt    time.Time    `json:"started_at"`
+	FinishedAt   time.Time    `json:"finished_at"`
+	Files        []OrphanFile `json:"files"`
+	Count        int          `json:"count"`
+	Bytes        int64        `json:"bytes"`
+	Deleted      int          `json:"deleted"`
+	DeletedBytes int64        `json:"deleted_bytes"`
+	Failed       int          `json:"failed"`
+}
+
+// OrphanMetrics are counters since the server started
+type OrphanMetrics struct {
+	Runs         int        `json:"runs"`
+	LastRunAt    *time.Time `json:"last_run_at,omitempty"`
+	LastDuration string     `json:"last_duration,omitempty"`
+	LastDeleted  int        `json:"last_deleted"`
+	TotalDeleted int        `json:"total_deleted"`
+	TotalBytes   int64      `json:"total_bytes"`
+	TotalFailed  int        `json:"total_failed"`
+}
+
+// OrphanCleanupService deletes uploaded files that were never used by a
+// document once they are older than the grace period
+type OrphanCleanupService struct {
+	Blobs       *Blobs
+	Storage     storage.Storage
+	GracePeriod time.Duration
+	Interval    time.Duration
+
+	mu      sync.Mutex
+	metrics OrphanMetrics
+	ticker  *time.Ticker
+	done    chan bool
+}
+
+func NewOrphanCleanupService(blobs *Blobs, store storage.Storage, gracePeriod, interval time.Duration) *OrphanCleanupService {
+	return &OrphanCleanupService{
+		Blobs:       blobs,
+		Storage:     store,
+		GracePeriod: gracePeriod,
+		Interval:    interval,
+		done:        make(chan bool),
+	}
+}
+
+// Start begins the background job to remove orphaned uploads
+func (s *OrphanCleanupService) Start() {
+	s.ticker = time.NewTicker(s.Interval)
+
+	go func() {
+		// Run immediately on start
+		s.collect()
+
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-s.ticker.C:
+				s.collect()
+			}
+		}
+	}()
+
+	log.Printf("🧹 Orphan cleanup service started (every %s, grace period %s)", s.Interval, s.GracePeriod)
+}
+
+// Stop gracefully stops the background service
+func (s *OrphanCleanupService) Stop() {
+	if s.ticker != nil {
+		s.ticker.Stop()
+	}
+	s.done <- true
+	log.Println("🧹 Orphan cleanup service stopped")
+}
+
+func (s *OrphanCleanupService) collect() {
+	if _, err := s.Collect(false); err != nil {
+		log.Printf("❌ Orphaned upload cleanup failed: %v", err)
+	}
+}
+
+// Metrics returns the counters of the deleting runs
+func (s *OrphanCleanupService) Metrics() OrphanMetrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.metrics
+}
+
+// Collect finds orphaned files and, unless dryRun is set, deletes them.
+// Each file is checked again right before it is deleted.
+func (s *OrphanCleanupService) Collect(dryRun bool) (*OrphanReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx := context.Background()
+	report := &OrphanReport{
+		DryRun:    dryRun,
+		Cutoff:    time.Now().Add(-s.GracePeriod),
+		StartedAt: time.Now(),
+		Files:     []OrphanFile{},
+	}
+
+	files, err := s.find(ctx, report.Cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		report.Count++
+		report.Bytes += file.Size
+		report.Files = append(report.Files, file)
+		if dryRun {
+			continue
+		}
+
+		deleted, err := s.remove(ctx, file)
+		switch {
+		case err != nil:
+			report.Failed++
+			log.Printf("❌ Failed to delete orphaned %s %s: %v", file.Kind, file.Key, err)
+		case deleted:
+			report.Deleted++
+			report.DeletedBytes += file.Size
+			log.Printf("🗑️  Deleted orphaned %s %s (%d bytes, uploaded %s)", file.Kind, file.Key, file.Size,
+				file.UploadedAt.Format(time.RFC3339))
+		}
+	}
+	report.FinishedAt = time.Now()
+
+	if !dryRun {
+		s.metrics.Runs++
+		s.metrics.LastRunAt = &report.FinishedAt
+		s.metrics.LastDuration = report.FinishedAt.Sub(report.StartedAt).Round(time.Millisecond).String()
+		s.metrics.LastDeleted = report.Deleted
+		s.metrics.TotalDeleted += report.Deleted
+		s.metrics.TotalFailed += report.Failed
+		s.metrics.TotalBytes += report.DeletedBytes
+		if report.Count > 0 {
+			log.Printf("🧹 Orphan cleanup: %d found, %d deleted, %d failed", report.Count, report.Deleted, report.Failed)
+		}
+	}
+	return report, nil
+}
+
+// find lists unreferenced uploads and stored objects older than the cutoff
+func (s *OrphanCleanupService) find(ctx context.Context, cutoff time.Time) ([]OrphanFile, error) {
+	uploads, err := models.OrphanedUploads(cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []OrphanFile
+	for _, upload := range uploads {
+		files = append(files, OrphanFile{
+			Kind:       OrphanUpload,
+			Key:        upload.StoredName,
+			Name:       upload.OriginalName,
+			Size:       upload.Size,
+			UploadedAt: upload.CreatedAt,
+			UploaderID: upload.UploaderID,
+		})
+	}
+
+	// Objects in storage that no upload or blob record knows about. Objects
+	// without a timestamp are never considered old enough.
+	err = s.Storage.List(ctx, func(obj storage.Object) error {
+		if obj.ModTime.IsZero() || !obj.ModTime.Before(cutoff) {
+			return nil
+		}
+
+		kind, err := s.classify(obj.Key)
+		if err != nil {
+			return err
+		}
+		if kind != "" {
+			files = append(files, OrphanFile{Kind: kind, Key: obj.Key, Size: obj.Size, UploadedAt: obj.ModTime})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// classify returns the orphan kind of a stored object, or "" if it is in use
+func (s *OrphanCleanupService) classify(key string) (string, error) {
+	if hash := strings.TrimPrefix(key, models.BlobKey("")); hash != key {
+		return classifyBlobFile(hash, OrphanBlob)
+	}
+	if hash := models.PreviewHash(key); hash != "" {
+		return classifyBlobFile(hash, OrphanPreview)
+	}
+
+	// Recorded uploads are handled through their record
+	var count int64
+	if err := models.DB.Model(&models.Upload{}).Where("stored_name = ?", key).Count(&count).Error; err != nil {
+		return "", err
+	}
+	if count > 0 {
+		return "", nil
+	}
+
+	referenced, err := models.UploadReferenced(key)
+	if err != nil || referenced {
+		return "", err
+	}
+	return OrphanLegacy, nil
+}
+
+// classifyBlobFile returns kind if no blob record with the hash exists
+func classifyBlobFile(hash, kind string) (string, error) {
+	var count int64
+	if err := models.DB.Model(&models.Blob{}).Where("hash = ?", hash).Count(&count).Error; err != nil {
+		return "", err
+	}
+	if count == 0 {
+		return kind, nil
+	}
+	return "", nil
+}
+
+// remove deletes an orphaned file if it is still unused
+func (s *OrphanCleanupService) remove(ctx context.Context, file OrphanFile) (bool, error) {
+	switch file.Kind {
+	case OrphanUpload:
+		referenced, err := models.UploadReferenced(file.Key)
+		if err != nil || referenced {
+			return false, err
+		}
+		return true, s.Blobs.Remove(ctx, file.Key)
+
+	default:
+		kind, err := s.classify(file.Key)
+		if err != nil || kind != file.Kind {
+			return false, err
+		}
+		return true, s.Storage.Delete(ctx, file.Key)
+	}
+}
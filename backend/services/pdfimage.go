@@ -0,0 +1,684 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"regexp"
+	"strconv"
+
+	"golang.org/x/image/ccitt"
+)
+
+// A minimal PDF reader. For previews it finds the picture on the first
+// page: scanned documents are one image per page, so that image is the
+// rendering of the page; pages made of text and vector graphics are not
+// supported. Text extraction lives in pdftext.go.
+
+var errNoPageImage = errors.New("pdf: first page has no supported image")
+
+var (
+	pdfObjHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	pdfRootRef   = regexp.MustCompile(`/Root\s+(\d+)\s+\d+\s+R`)
+)
+
+type pdfName string
+
+type pdfRef int
+
+type pdfDict map[string]interface{}
+
+type pdfStream struct {
+	Dict pdfDict
+	Data []byte
+}
+
+type pdfFile struct {
+	data []byte
+	// offsets of top-level objects, later revisions win
+	offsets map[int]int
+	// objects stored inside object streams
+	packed map[int][]byte
+	cache  map[int]interface{}
+	depth  int
+}
+
+func openPDF(data []byte) *pdfFile {
+	f := &pdfFile{data: data, offsets: map[int]int{}, packed: map[int][]byte{}, cache: map[int]interface{}{}}
+	for _, m := range pdfObjHeader.FindAllSubmatchIndex(data, -1) {
+		num, err := strconv.Atoi(string(data[m[2]:m[3]]))
+		if err == nil {
+			f.offsets[num] = m[1]
+		}
+	}
+
+	// PDF 1.5+ packs most dictionaries into compressed object streams
+	for num := range f.offsets {
+		stream, ok := f.object(num).(*pdfStream)
+		if !ok || stream.Dict["Type"] != pdfName("ObjStm") {
+			continue
+		}
+		f.unpackObjectStream(stream)
+	}
+	return f
+}
+
+func (f *pdfFile) unpackObjectStream(stream *pdfStream) {
+	data, err := f.decodeFlate(stream)
+	if err != nil {
+		return
+	}
+	n, _ := f.resolve(stream.Dict["N"]).(int)
+	first, _ := f.resolve(stream.Dict["First"]).(int)
+	if first <= 0 || first > len(data) {
+		return
+	}
+
+	p := &pdfParser{data: data[:first]}
+	var nums, offs []int
+	for i := 0; i < n; i++ {
+		num, ok1 := p.value().(int)
+		off, ok2 := p.value().(int)
+		if !ok1 || !ok2 {
+			break
+		}
+		nums = append(nums, num)
+		offs = append(offs, first+off)
+	}
+	for i, num := range nums {
+		end := len(data)
+		if i+1 < len(offs) {
+			end = offs[i+1]
+		}
+		if _, top := f.offsets[num]; !top && offs[i] < end && end <= len(data) {
+			f.packed[num] = data[offs[i]:end]
+		}
+	}
+}
+
+// object parses an indirect object
+func (f *pdfFile) object(num int) interface{} {
+	if v, ok := f.cache[num]; ok {
+		return v
+	}
+	// Guard against reference loops
+	if f.depth > 32 {
+		return nil
+	}
+	f.depth++
+	defer func() { f.depth-- }()
+
+	var v interface{}
+	if off, ok := f.offsets[num]; ok {
+		p := &pdfParser{data: f.data, pos: off}
+		v = p.value()
+		if dict, ok := v.(pdfDict); ok && p.keyword("stream") {
+			v = f.readStream(dict, p.pos)
+		}
+	} else if packed, ok := f.packed[num]; ok {
+		v = (&pdfParser{data: packed}).value()
+	}
+	f.cache[num] = v
+	return v
+}
+
+func (f *pdfFile) readStream(dict pdfDict, pos int) *pdfStream {
+	// The data starts after the end of line following "stream"
+	if pos < len(f.data) && f.data[pos] == '\r' {
+		pos++
+	}
+	if pos < len(f.data) && f.data[pos] == '\n' {
+		pos++
+	}
+
+	end := -1
+	if length, ok := f.resolve(dict["Length"]).(int); ok && length >= 0 && pos+length <= len(f.data) {
+		end = pos + length
+	} else if i := bytes.Index(f.data[pos:], []byte("endstream")); i >= 0 {
+		end = pos + i
+	}
+	if end < 0 {
+		return &pdfStream{Dict: dict}
+	}
+	return &pdfStream{Dict: dict, Data: f.data[pos:end]}
+}
+
+func (f *pdfFile) resolve(v interface{}) interface{} {
+	if ref, ok := v.(pdfRef); ok {
+		return f.object(int(ref))
+	}
+	return v
+}
+
+func (f *pdfFile) dict(v interface{}) pdfDict {
+	switch v := f.resolve(v).(type) {
+	case pdfDict:
+		return v
+	case *pdfStream:
+		return v.Dict
+	}
+	return nil
+}
+
+// catalog returns the document catalog named by the last trailer
+func (f *pdfFile) catalog() (pdfDict, error) {
+	matches := pdfRootRef.FindAllSubmatch(f.data, -1)
+	if len(matches) == 0 {
+		return nil, errors.New("pdf: no document catalog")
+	}
+	rootNum, _ := strconv.Atoi(string(matches[len(matches)-1][1]))
+	return f.dict(pdfRef(rootNum)), nil
+}
+
+// firstPage walks the page tree down its first branch and returns the page
+// with the resources it inherits
+func (f *pdfFile) firstPage() (pdfDict, pdfDict, error) {
+	catalog, err := f.catalog()
+	if err != nil {
+		return nil, nil, err
+	}
+	node := f.dict(catalog["Pages"])
+	var resources pdfDict
+	for i := 0; node != nil && i < 32; i++ {
+		if r := f.dict(node["Resources"]); r != nil {
+			resources = r
+		}
+		if node["Type"] == pdfName("Page") {
+			return node, resources, nil
+		}
+		kids, _ := f.resolve(node["Kids"]).([]interface{})
+		if len(kids) == 0 {
+			break
+		}
+		node = f.dict(kids[0])
+	}
+	return nil, nil, errors.New("pdf: no pages")
+}
+
+// largestImage finds the biggest image XObject, looking one level into forms
+func (f *pdfFile) largestImage(resources pdfDict, depth int) *pdfStream {
+	var best *pdfStream
+	bestArea := 0
+	for _, ref := range f.dict(resources["XObject"]) {
+		stream, ok := f.resolve(ref).(*pdfStream)
+		if !ok {
+			continue
+		}
+
+		candidate := stream
+		if stream.Dict["Subtype"] == pdfName("Form") {
+			if depth > 0 {
+				continue
+			}
+			candidate = f.largestImage(f.dict(stream.Dict["Resources"]), depth+1)
+			if candidate == nil {
+				continue
+			}
+		} else if stream.Dict["Subtype"] != pdfName("Image") {
+			continue
+		}
+
+		w, _ := f.resolve(candidate.Dict["Width"]).(int)
+		h, _ := f.resolve(candidate.Dict["Height"]).(int)
+		if w*h > bestArea {
+			best, bestArea = candidate, w*h
+		}
+	}
+	return best
+}
+
+func (f *pdfFile) decodeFlate(stream *pdfStream) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(stream.Data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	// Decompression bombs stop at the pixel budget of a preview
+	data, err := io.ReadAll(io.LimitReader(r, maxPreviewPixels*4+1))
+	if err != nil && len(data) == 0 {
+		return nil, err
+	}
+	return data, nil
+}
+
+// decodeImage turns an image XObject into an image
+func (f *pdfFile) decodeImage(stream *pdfStream) (image.Image, error) {
+	dict := stream.Dict
+	width, _ := f.resolve(dict["Width"]).(int)
+	height, _ := f.resolve(dict["Height"]).(int)
+	if width <= 0 || height <= 0 || width*height > maxPreviewPixels {
+		return nil, fmt.Errorf("pdf: unsupported image size %dx%d", width, height)
+	}
+
+	var filters []interface{}
+	var params []interface{}
+	switch v := f.resolve(dict["Filter"]).(type) {
+	case pdfName:
+		filters = []interface{}{v}
+		params = []interface{}{f.resolve(dict["DecodeParms"])}
+	case []interface{}:
+		filters = v
+		params, _ = f.resolve(dict["DecodeParms"]).([]interface{})
+	}
+
+	data := stream.Data
+	for i, filter := range filters {
+		var parms pdfDict
+		if i < len(params) {
+			parms = f.dict(params[i])
+		}
+
+		switch filter {
+		case pdfName("DCTDecode"):
+			return jpeg.Decode(bytes.NewReader(data))
+
+		case pdfName("CCITTFaxDecode"):
+			return f.decodeCCITT(data, parms, width, height)
+
+		case pdfName("FlateDecode"):
+			decoded, err := f.decodeFlate(&pdfStream{Data: data})
+			if err != nil {
+				return nil, err
+			}
+			data = decoded
+			if predictor, _ := f.resolve(parms["Predictor"]).(int); predictor >= 10 {
+				colors, _ := f.resolve(parms["Colors"]).(int)
+				bpc, _ := f.resolve(parms["BitsPerComponent"]).(int)
+				columns, _ := f.resolve(parms["Columns"]).(int)
+				data, err = pngUnpredict(data, max(colors, 1), max(bpc, 8), max(columns, 1))
+				if err != nil {
+					return nil, err
+				}
+			}
+
+		default:
+			return nil, fmt.Errorf("pdf: unsupported image filter %v", filter)
+		}
+	}
+
+	return f.rawImage(dict, data, width, height)
+}
+
+func (f *pdfFile) decodeCCITT(data []byte, parms pdfDict, width, height int) (image.Image, error) {
+	k, _ := f.resolve(parms["K"]).(int)
+	sub := ccitt.Group3
+	if k < 0 {
+		sub = ccitt.Group4
+	} else if k > 0 {
+		return nil, errors.New("pdf: mixed 1D/2D CCITT images are not supported")
+	}
+	if columns, ok := f.resolve(parms["Columns"]).(int); ok && columns > 0 {
+		width = columns
+	}
+
+	opts := &ccitt.Options{
+		Align:  f.resolve(parms["EncodedByteAlign"]) == true,
+		Invert: f.resolve(parms["BlackIs1"]) == true,
+	}
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	if err := ccitt.DecodeIntoGray(img, bytes.NewReader(data), ccitt.MSB, sub, opts); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// rawImage builds an image from uncompressed samples
+func (f *pdfFile) rawImage(dict pdfDict, data []byte, width, height int) (image.Image, error) {
+	bpc, _ := f.resolve(dict["BitsPerComponent"]).(int)
+	if f.resolve(dict["ImageMask"]) == true {
+		bpc = 1
+	}
+
+	components := 0
+	switch cs := f.resolve(dict["ColorSpace"]).(type) {
+	case pdfName:
+		components = map[pdfName]int{"DeviceGray": 1, "CalGray": 1, "DeviceRGB": 3, "CalRGB": 3, "DeviceCMYK": 4}[cs]
+	case []interface{}:
+		if len(cs) == 2 && cs[0] == pdfName("ICCBased") {
+			if profile, ok := f.resolve(cs[1]).(*pdfStream); ok {
+				components, _ = f.resolve(profile.Dict["N"]).(int)
+			}
+		}
+	case nil:
+		components = 1
+	}
+
+	switch {
+	case bpc == 1 && components == 1:
+		img := image.NewGray(image.Rect(0, 0, width, height))
+		stride := (width + 7) / 8
+		if len(data) < stride*height {
+			return nil, errors.New("pdf: truncated image data")
+		}
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				if data[y*stride+x/8]&(0x80>>(x%8)) != 0 {
+					img.Pix[y*img.Stride+x] = 0xFF
+				}
+			}
+		}
+		return img, nil
+
+	case bpc == 8 && (components == 1 || components == 3 || components == 4):
+		if len(data) < width*height*components {
+			return nil, errors.New("pdf: truncated image data")
+		}
+		switch components {
+		case 1:
+			return &image.Gray{Pix: data[:width*height], Stride: width, Rect: image.Rect(0, 0, width, height)}, nil
+		case 4:
+			return &image.CMYK{Pix: data[:width*height*4], Stride: width * 4, Rect: image.Rect(0, 0, width, height)}, nil
+		}
+		img := image.NewRGBA(image.Rect(0, 0, width, height))
+		for i := 0; i < width*height; i++ {
+			img.Pix[i*4] = data[i*3]
+			img.Pix[i*4+1] = data[i*3+1]
+			img.Pix[i*4+2] = data[i*3+2]
+			img.Pix[i*4+3] = 0xFF
+		}
+		return img, nil
+	}
+	return nil, fmt.Errorf("pdf: unsupported color format (%d components, %d bits)", components, bpc)
+}
+
+// pngUnpredict reverses the PNG row filters used by FlateDecode predictors
+func pngUnpredict(data []byte, colors, bpc, columns int) ([]byte, error) {
+	bpp := max(colors*bpc/8, 1)
+	rowLen := (colors*bpc*columns + 7) / 8
+	if len(data)%(rowLen+1) != 0 {
+		return nil, errors.New("pdf: bad predictor data")
+	}
+
+	out := make([]byte, 0, len(data)/(rowLen+1)*rowLen)
+	prev := make([]byte, rowLen)
+	for off := 0; off < len(data); off += rowLen + 1 {
+		filter, row := data[off], data[off+1:off+1+rowLen]
+		cur := make([]byte, rowLen)
+		for i := range row {
+			var left, up, upLeft byte
+			if i >= bpp {
+				left, upLeft = cur[i-bpp], prev[i-bpp]
+			}
+			up = prev[i]
+			switch filter {
+			case 0:
+				cur[i] = row[i]
+			case 1:
+				cur[i] = row[i] + left
+			case 2:
+				cur[i] = row[i] + up
+			case 3:
+				cur[i] = row[i] + byte((int(left)+int(up))/2)
+			case 4:
+				cur[i] = row[i] + paeth(left, up, upLeft)
+			default:
+				return nil, errors.New("pdf: bad predictor filter")
+			}
+		}
+		out = append(out, cur...)
+		prev = cur
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// pdfFirstPageImage returns the largest image on the first page
+func pdfFirstPageImage(data []byte) (image.Image, error) {
+	f := openPDF(data)
+	page, resources, err := f.firstPage()
+	if err != nil {
+		return nil, err
+	}
+	if r := f.dict(page["Resources"]); r != nil {
+		resources = r
+	}
+
+	stream := f.largestImage(resources, 0)
+	if stream == nil {
+		return nil, errNoPageImage
+	}
+	img, err := f.decodeImage(stream)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNoPageImage, err)
+	}
+	return img, nil
+}
+
+// pdfParser reads PDF values: dictionaries, arrays, names, numbers,
+// strings and references
+type pdfParser struct {
+	data []byte
+	pos  int
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return isPDFSpace(c) || bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+func (p *pdfParser) skipSpace() {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if c == '%' {
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		if !isPDFSpace(c) {
+			return
+		}
+		p.pos++
+	}
+}
+
+func (p *pdfParser) token() string {
+	start := p.pos
+	for p.pos < len(p.data) && !isPDFDelimiter(p.data[p.pos]) {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+// keyword consumes the keyword if it comes next as a whole word
+func (p *pdfParser) keyword(word string) bool {
+	p.skipSpace()
+	if !bytes.HasPrefix(p.data[p.pos:], []byte(word)) {
+		return false
+	}
+	end := p.pos + len(word)
+	if !isPDFDelimiter(word[len(word)-1]) && end < len(p.data) && !isPDFDelimiter(p.data[end]) {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+func (p *pdfParser) value() interface{} {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil
+	}
+
+	switch c := p.data[p.pos]; {
+	case c == '/':
+		p.pos++
+		return pdfName(p.token())
+
+	case c == '<' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '<':
+		p.pos += 2
+		dict := pdfDict{}
+		for {
+			p.skipSpace()
+			if p.pos >= len(p.data) || p.keyword(">>") {
+				return dict
+			}
+			key, ok := p.value().(pdfName)
+			if !ok {
+				return dict
+			}
+			dict[string(key)] = p.value()
+		}
+
+	case c == '[':
+		p.pos++
+		var arr []interface{}
+		for {
+			p.skipSpace()
+			if p.pos >= len(p.data) || p.keyword("]") {
+				return arr
+			}
+			before := p.pos
+			arr = append(arr, p.value())
+			if p.pos == before {
+				p.pos++
+			}
+		}
+
+	case c == '(':
+		return p.literalString()
+
+	case c == '<':
+		end := bytes.IndexByte(p.data[p.pos:], '>')
+		if end < 0 {
+			p.pos = len(p.data)
+			return nil
+		}
+		s := hexString(p.data[p.pos+1 : p.pos+end])
+		p.pos += end + 1
+		return s
+
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		tok := p.token()
+		n, err := strconv.Atoi(tok)
+		if err != nil {
+			fl, _ := strconv.ParseFloat(tok, 64)
+			return fl
+		}
+		// "N G R" is a reference
+		save := p.pos
+		p.skipSpace()
+		if gen := p.token(); gen != "" {
+			if _, err := strconv.Atoi(gen); err == nil && p.keyword("R") {
+				return pdfRef(n)
+			}
+		}
+		p.pos = save
+		return n
+	}
+
+	switch tok := p.token(); tok {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "":
+		p.pos++
+	}
+	return nil
+}
+
+func (p *pdfParser) literalString() string {
+	p.pos++
+	var buf []byte
+	for depth := 1; p.pos < len(p.data); p.pos++ {
+		c := p.data[p.pos]
+		switch c {
+		case '\\':
+			p.pos++
+			if p.pos >= len(p.data) {
+				continue
+			}
+			switch e := p.data[p.pos]; e {
+			case 'n':
+				buf = append(buf, '\n')
+			case 'r':
+				buf = append(buf, '\r')
+			case 't':
+				buf = append(buf, '\t')
+			case 'b':
+				buf = append(buf, '\b')
+			case 'f':
+				buf = append(buf, '\f')
+			case '\r', '\n':
+				// Line continuation
+				if e == '\r' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '\n' {
+					p.pos++
+				}
+			default:
+				if e >= '0' && e <= '7' {
+					// Up to three octal digits
+					n := 0
+					for i := 0; i < 3 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+						n = n*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					p.pos--
+					buf = append(buf, byte(n))
+				} else {
+					buf = append(buf, e)
+				}
+			}
+			continue
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				p.pos++
+				return string(buf)
+			}
+		}
+		buf = append(buf, c)
+	}
+	return string(buf)
+}
+
+// hexString decodes <...> strings; whitespace is ignored and a missing
+// last digit counts as 0
+func hexString(data []byte) string {
+	var buf []byte
+	var digits []byte
+	for _, c := range data {
+		if isPDFSpace(c) {
+			continue
+		}
+		digits = append(digits, c)
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	for i := 0; i < len(digits); i += 2 {
+		n, err := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		if err != nil {
+			break
+		}
+		buf = append(buf, byte(n))
+	}
+	return string(buf)
+}
@@ -0,0 +1,397 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Text extraction from PDF content streams. Only the text drawing operators
+// are interpreted; layout is reduced to spaces and line breaks, which is
+// enough for search.
+
+const maxPDFTextPages = 1000
+
+// pdfFont maps the codes of a string to text
+type pdfFont struct {
+	// Code widths in bytes from the ToUnicode codespace ranges
+	widths []int
+	cmap   map[string]string
+	// Composite fonts without ToUnicode can not be decoded
+	composite bool
+}
+
+// pdfText collects the text of a document
+type pdfText struct {
+	f     *pdfFile
+	out   strings.Builder
+	fonts map[pdfRef]*pdfFont
+}
+
+// pdfExtractText returns the text of every page up to maxRunes
+func pdfExtractText(data []byte, maxRunes int) (string, error) {
+	f := openPDF(data)
+	catalog, err := f.catalog()
+	if err != nil {
+		return "", err
+	}
+	t := &pdfText{f: f, fonts: map[pdfRef]*pdfFont{}}
+
+	pages := 0
+	var walk func(node pdfDict, resources pdfDict, depth int)
+	walk = func(node pdfDict, resources pdfDict, depth int) {
+		if node == nil || depth > 32 || pages >= maxPDFTextPages || t.out.Len() >= maxRunes*4 {
+			return
+		}
+		if r := f.dict(node["Resources"]); r != nil {
+			resources = r
+		}
+		if node["Type"] == pdfName("Page") || node["Kids"] == nil {
+			pages++
+			t.page(node, resources)
+			return
+		}
+		kids, _ := f.resolve(node["Kids"]).([]interface{})
+		for _, kid := range kids {
+			walk(f.dict(kid), resources, depth+1)
+		}
+	}
+	walk(f.dict(catalog["Pages"]), nil, 0)
+
+	return truncateRunes(t.out.String(), maxRunes), nil
+}
+
+func (t *pdfText) page(page pdfDict, resources pdfDict) {
+	var content []byte
+	switch v := t.f.resolve(page["Contents"]).(type) {
+	case *pdfStream:
+		content = t.streamData(v)
+	case []interface{}:
+		for _, part := range v {
+			if stream, ok := t.f.resolve(part).(*pdfStream); ok {
+				content = append(content, t.streamData(stream)...)
+				content = append(content, '\n')
+			}
+		}
+	}
+	t.content(content, resources, 0)
+	t.out.WriteString("\n")
+}
+
+// streamData returns the decoded content of a stream, nil if it uses a
+// filter other than FlateDecode
+func (t *pdfText) streamData(stream *pdfStream) []byte {
+	switch filter := t.f.resolve(stream.Dict["Filter"]).(type) {
+	case nil:
+		return stream.Data
+	case pdfName:
+		if filter != "FlateDecode" {
+			return nil
+		}
+	case []interface{}:
+		if len(filter) != 1 || filter[0] != pdfName("FlateDecode") {
+			return nil
+		}
+	}
+	data, err := t.f.decodeFlate(stream)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// content runs the text operators of a content stream
+func (t *pdfText) content(data []byte, resources pdfDict, depth int) {
+	p := &pdfParser{data: data}
+	fonts := t.f.dict(resources["Font"])
+	var font *pdfFont
+	var operands []interface{}
+
+	for {
+		p.skipSpace()
+		if p.pos >= len(data) {
+			return
+		}
+
+		if c := data[p.pos]; c == '/' || c == '<' || c == '[' || c == '(' || c == '+' || c == '-' ||
+			c == '.' || (c >= '0' && c <= '9') {
+			operands = append(operands, p.value())
+			continue
+		}
+
+		op := p.token()
+		if op == "" {
+			// Stray delimiter
+			p.pos++
+			operands = operands[:0]
+			continue
+		}
+
+		switch op {
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[0].(pdfName); ok {
+					font = t.font(fonts[string(name)])
+				}
+			}
+		case "Tj":
+			t.show(font, operands, 1)
+		case "'", "\"":
+			t.out.WriteString("\n")
+			t.show(font, operands, 1)
+		case "TJ":
+			if len(operands) > 0 {
+				arr, _ := operands[len(operands)-1].([]interface{})
+				for _, item := range arr {
+					switch v := item.(type) {
+					case string:
+						t.out.WriteString(font.decode(v))
+					case int:
+						// Large negative kerning separates words
+						if v < -200 {
+							t.out.WriteString(" ")
+						}
+					case float64:
+						if v < -200 {
+							t.out.WriteString(" ")
+						}
+					}
+				}
+			}
+		case "Td", "TD", "Tm", "T*":
+			t.out.WriteString(" ")
+		case "ET":
+			t.out.WriteString("\n")
+		case "Do":
+			// Text inside form XObjects
+			if len(operands) > 0 && depth < 4 {
+				if name, ok := operands[0].(pdfName); ok {
+					form, ok := t.f.resolve(t.f.dict(resources["XObject"])[string(name)]).(*pdfStream)
+					if ok && form.Dict["Subtype"] == pdfName("Form") {
+						formResources := t.f.dict(form.Dict["Resources"])
+						if formResources == nil {
+							formResources = resources
+						}
+						t.content(t.streamData(form), formResources, depth+1)
+					}
+				}
+			}
+		case "ID":
+			// Skip inline image data up to EI
+			if i := bytes.Index(data[p.pos:], []byte("EI")); i >= 0 {
+				p.pos += i + 2
+			} else {
+				p.pos = len(data)
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+// show writes the string among the last n operands
+func (t *pdfText) show(font *pdfFont, operands []interface{}, n int) {
+	if len(operands) < n {
+		return
+	}
+	if s, ok := operands[len(operands)-n].(string); ok {
+		t.out.WriteString(font.decode(s))
+	}
+}
+
+// font loads the encoding of a font dictionary
+func (t *pdfText) font(v interface{}) *pdfFont {
+	ref, isRef := v.(pdfRef)
+	if font, ok := t.fonts[ref]; ok && isRef {
+		return font
+	}
+	dict := t.f.dict(v)
+	if dict == nil {
+		return nil
+	}
+
+	font := &pdfFont{composite: dict["Subtype"] == pdfName("Type0")}
+	if toUnicode, ok := t.f.resolve(dict["ToUnicode"]).(*pdfStream); ok {
+		font.parseCMap(t.streamData(toUnicode))
+	}
+	if isRef {
+		t.fonts[ref] = font
+	}
+	return font
+}
+
+// parseCMap reads the codespace ranges and the bfchar/bfrange mappings
+func (font *pdfFont) parseCMap(data []byte) {
+	font.cmap = map[string]string{}
+	p := &pdfParser{data: data}
+	var operands []interface{}
+	state := ""
+
+	for {
+		p.skipSpace()
+		if p.pos >= len(data) {
+			return
+		}
+		if c := data[p.pos]; c == '/' || c == '<' || c == '[' || c == '(' || (c >= '0' && c <= '9') {
+			v := p.value()
+			switch state {
+			case "codespace":
+				if s, ok := v.(string); ok && len(operands) == 1 {
+					font.widths = append(font.widths, len(s))
+					operands = operands[:0]
+					continue
+				}
+			case "bfchar":
+				if len(operands) == 1 {
+					src, _ := operands[0].(string)
+					if dst, ok := v.(string); ok {
+						font.cmap[src] = utf16BE(dst)
+					}
+					operands = operands[:0]
+					continue
+				}
+			case "bfrange":
+				if len(operands) == 2 {
+					font.addRange(operands[0], operands[1], v)
+					operands = operands[:0]
+					continue
+				}
+			}
+			operands = append(operands, v)
+			continue
+		}
+
+		switch op := p.token(); op {
+		case "begincodespacerange":
+			state = "codespace"
+		case "beginbfchar":
+			state = "bfchar"
+		case "beginbfrange":
+			state = "bfrange"
+		case "endcodespacerange", "endbfchar", "endbfrange":
+			state = ""
+		case "":
+			p.pos++
+		}
+		operands = operands[:0]
+	}
+}
+
+func (font *pdfFont) addRange(lo, hi, dst interface{}) {
+	start, ok1 := lo.(string)
+	end, ok2 := hi.(string)
+	if !ok1 || !ok2 || len(start) != len(end) || len(start) == 0 || len(start) > 4 {
+		return
+	}
+	from, to := codeValue(start), codeValue(end)
+	if to < from || to-from > 0xFFFF {
+		return
+	}
+
+	for code := from; code <= to; code++ {
+		key := codeString(code, len(start))
+		switch d := dst.(type) {
+		case string:
+			// Consecutive codes map to consecutive characters
+			base := []byte(d)
+			if len(base) == 0 {
+				return
+			}
+			out := append([]byte(nil), base...)
+			if len(out) >= 2 {
+				last := uint32(out[len(out)-2])<<8 | uint32(out[len(out)-1]) + code - from
+				out[len(out)-2], out[len(out)-1] = byte(last>>8), byte(last)
+			}
+			font.cmap[key] = utf16BE(string(out))
+		case []interface{}:
+			if i := int(code - from); i < len(d) {
+				if s, ok := d[i].(string); ok {
+					font.cmap[key] = utf16BE(s)
+				}
+			}
+		}
+	}
+}
+
+func codeValue(s string) uint32 {
+	var v uint32
+	for i := 0; i < len(s); i++ {
+		v = v<<8 | uint32(s[i])
+	}
+	return v
+}
+
+func codeString(v uint32, width int) string {
+	b := make([]byte, width)
+	for i := width - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return string(b)
+}
+
+func utf16BE(s string) string {
+	if len(s)%2 == 1 {
+		s += "\x00"
+	}
+	units := make([]uint16, len(s)/2)
+	for i := range units {
+		units[i] = uint16(s[2*i])<<8 | uint16(s[2*i+1])
+	}
+	return string(utf16.Decode(units))
+}
+
+// decode turns the bytes of a shown string into text
+func (font *pdfFont) decode(s string) string {
+	if font == nil || (font.cmap == nil && !font.composite) {
+		// Simple fonts mostly use a Latin encoding
+		out, err := charmap.Windows1252.NewDecoder().String(s)
+		if err != nil {
+			return ""
+		}
+		return out
+	}
+	if font.cmap == nil {
+		return ""
+	}
+
+	widths := font.widths
+	if len(widths) == 0 {
+		widths = []int{1, 2}
+		if font.composite {
+			widths = []int{2}
+		}
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(s); {
+		matched := false
+		for _, w := range widths {
+			if i+w <= len(s) {
+				if text, ok := font.cmap[s[i:i+w]]; ok {
+					out.WriteString(text)
+					i += w
+					matched = true
+					break
+				}
+			}
+		}
+		if !matched {
+			i += widths[0]
+		}
+	}
+	return out.String()
+}
+
+// truncateRunes cuts s to at most n runes
+func truncateRunes(s string, n int) string {
+	count := 0
+	for i := range s {
+		if count == n {
+			return s[:i]
+		}
+		count++
+	}
+	return s
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"time"
+
+	"synergy_dms/models"
+	"synergy_dms/storage"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	// Longest side of the rendered images
+	previewLargeSize     = 1024
+	previewThumbnailSize = 256
+
+	// Larger sources are not previewed to keep memory in check
+	maxPreviewSourceSize = 64 * 1024 * 1024
+	maxPreviewPixels     = 40 * 1000 * 1000
+)
+
+var errPreviewUnsupported = errors.New("preview not supported")
+
+// PreviewService renders thumbnails of uploaded images and of the first
+// page of PDFs in the background. Previews belong to the blob, so identical
+// files are rendered once.
+type PreviewService struct {
+	Storage storage.Storage
+	Workers int
+
+	queue  chan string
+	ticker *time.Ticker
+	done   chan bool
+}
+
+func NewPreviewService(store storage.Storage, workers int) *PreviewService {
+	if workers < 1 {
+		workers = 1
+	}
+	return &PreviewService{
+		Storage: store,
+		Workers: workers,
+		queue:   make(chan string, 1000),
+		done:    make(chan bool),
+	}
+}
+
+// Enqueue schedules the preview of a blob. When the queue is full the
+// periodic retry picks it up later.
+func (s *PreviewService) Enqueue(hash string) {
+	if hash == "" {
+		return
+	}
+	select {
+	case s.queue <- hash:
+	default:
+	}
+}
+
+// Start launches the workers and the retry loop
+func (s *PreviewService) Start() {
+	for i := 0; i < s.Workers; i++ {
+		go func() {
+			for hash := range s.queue {
+				s.render(hash)
+			}
+		}()
+	}
+
+	s.ticker = time.NewTicker(5 * time.Minute)
+	go func() {
+		// Pick up previews left undone by a restart
+		s.requeue()
+
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-s.ticker.C:
+				s.requeue()
+			}
+		}
+	}()
+
+	log.Printf("🖼️  Preview service started (%d workers)", s.Workers)
+}
+
+// Stop halts the retry loop. Previews in progress finish on their own.
+func (s *PreviewService) Stop() {
+	if s.ticker != nil {
+		s.ticker.Stop()
+	}
+	s.done <- true
+	log.Println("🖼️  Preview service stopped")
+}
+
+// requeue schedules every blob that is still waiting for its preview
+func (s *PreviewService) requeue() {
+	var hashes []string
+	models.DB.Model(&models.Blob{}).Where("preview_status = ?", models.PreviewPending).
+		Pluck("hash", &hashes)
+	for _, hash := range hashes {
+		s.Enqueue(hash)
+	}
+}
+
+func (s *PreviewService) render(hash string) {
+	var blob models.Blob
+	if err := models.DB.First(&blob, "hash = ?", hash).Error; err != nil {
+		return
+	}
+	if blob.PreviewStatus != models.PreviewPending {
+		return
+	}
+
+	status := models.PreviewReady
+	err := s.renderBlob(&blob)
+	switch {
+	case errors.Is(err, errPreviewUnsupported) || errors.Is(err, errNoPageImage):
+		status = models.PreviewUnsupported
+	case errors.Is(err, storage.ErrNotFound):
+		status = models.PreviewFailed
+	case err != nil && isStorageError(err):
+		// Left pending, the retry loop tries again
+		log.Printf("⚠️  Could not render preview of %s: %v", hash, err)
+		return
+	case err != nil:
+		status = models.PreviewFailed
+		log.Printf("❌ Failed to render preview of %s: %v", hash, err)
+	}
+
+	models.DB.Model(&blob).UpdateColumn("preview_status", status)
+}
+
+// storageError marks failures to talk to the storage backend
+type storageError struct{ err error }
+
+func (e storageError) Error() string { return e.err.Error() }
+func (e storageError) Unwrap() error { return e.err }
+
+func isStorageError(err error) bool {
+	var se storageError
+	return errors.As(err, &se)
+}
+
+// renderBlob decodes the content and stores both preview sizes
+func (s *PreviewService) renderBlob(blob *models.Blob) error {
+	if !previewable(blob.MimeType) || blob.Size > maxPreviewSourceSize {
+		return errPreviewUnsupported
+	}
+
+	ctx := context.Background()
+	r, _, err := s.Storage.Get(ctx, blob.Key())
+	if errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+	if err != nil {
+		return storageError{err}
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxPreviewSourceSize))
+	r.Close()
+	if err != nil {
+		return storageError{err}
+	}
+
+	img, err := decodePreviewSource(data, blob.MimeType)
+	if err != nil {
+		return err
+	}
+
+	for size, limit := range map[string]int{
+		models.PreviewSizeLarge:     previewLargeSize,
+		models.PreviewSizeThumbnail: previewThumbnailSize,
+	} {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, scaleToFit(img, limit), &jpeg.Options{Quality: 80}); err != nil {
+			return err
+		}
+		if err := s.Storage.Put(ctx, models.PreviewKey(blob.Hash, size), &buf, int64(buf.Len()),
+			"image/jpeg"); err != nil {
+			return storageError{err}
+		}
+	}
+	return nil
+}
+
+func previewable(mimeType string) bool {
+	switch mimeType {
+	case "image/png", "image/jpeg", "image/gif", "application/pdf":
+		return true
+	}
+	return false
+}
+
+// decodePreviewSource turns an image or the first page of a PDF into an
+// image, refusing pictures too large to hold in memory
+func decodePreviewSource(data []byte, mimeType string) (image.Image, error) {
+	if mimeType == "application/pdf" {
+		return pdfFirstPageImage(data)
+	}
+
+	var decodeConfig func(io.Reader) (image.Config, error)
+	var decode func(io.Reader) (image.Image, error)
+	switch mimeType {
+	case "image/png":
+		decodeConfig, decode = png.DecodeConfig, png.Decode
+	case "image/jpeg":
+		decodeConfig, decode = jpeg.DecodeConfig, jpeg.Decode
+	case "image/gif":
+		// The first frame of animations
+		decodeConfig, decode = gif.DecodeConfig, gif.Decode
+	default:
+		return nil, errPreviewUnsupported
+	}
+
+	cfg, err := decodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxPreviewPixels {
+		return nil, fmt.Errorf("%w: image is %dx%d", errPreviewUnsupported, cfg.Width, cfg.Height)
+	}
+	return decode(bytes.NewReader(data))
+}
+
+// scaleToFit shrinks the image so its longest side is at most limit pixels.
+// Transparent areas become white because JPEG has no alpha channel.
+func scaleToFit(img image.Image, limit int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > limit || height > limit {
+		if width >= height {
+			width, height = limit, height*limit/width
+		} else {
+			width, height = width*limit/height, limit
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, max(width, 1), max(height, 1)))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.BiLinear.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+	return dst
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"synergy_dms/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrUploadNotFound is returned for unknown, foreign or expired uploads
+	ErrUploadNotFound = errors.New("upload not found")
+	// ErrOffsetMismatch is returned when a chunk does not continue the data received so far
+	ErrOffsetMismatch = errors.New("upload offset mismatch")
+)
+
+// ResumableUploads keeps partially received tus uploads on local disk and
+// removes the ones that were not finished in time
+type ResumableUploads struct {
+	Dir     string
+	TTL     time.Duration
+	MaxSize int64
+
+	locks  sync.Map
+	ticker *time.Ticker
+	done   chan bool
+}
+
+func NewResumableUploads(dir string, ttl time.Duration, maxSize int64) (*ResumableUploads, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &ResumableUploads{
+		Dir:     dir,
+		TTL:     ttl,
+		MaxSize: maxSize,
+		done:    make(chan bool),
+	}, nil
+}
+
+func (r *ResumableUploads) path(upload *models.ResumableUpload) string {
+	return filepath.Join(r.Dir, upload.ID)
+}
+
+// Create starts a new upload of length bytes
+func (r *ResumableUploads) Create(uploaderID uint, filename string, length int64) (*models.ResumableUpload, error) {
+	upload := models.ResumableUpload{
+		ID:         uuid.New().String(),
+		UploaderID: uploaderID,
+		Filename:   filename,
+		Length:     length,
+		ExpiresAt:  time.Now().Add(r.TTL),
+	}
+
+	f, err := os.OpenFile(r.path(&upload), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	if err := models.DB.Create(&upload).Error; err != nil {
+		os.Remove(r.path(&upload))
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// Find returns an unexpired upload of the user
+func (r *ResumableUploads) Find(id string, uploaderID uint) (*models.ResumableUpload, error) {
+	var upload models.ResumableUpload
+	if err := models.DB.Preload("Upload").
+		Where("id = ? AND uploader_id = ? AND expires_at > ?", id, uploaderID, time.Now()).
+		First(&upload).Error; err != nil {
+		return nil, ErrUploadNotFound
+	}
+	return &upload, nil
+}
+
+// Lock reserves an upload for one request at a time. It returns false when
+// another request is already writing to it.
+func (r *ResumableUploads) Lock(id string) (func(), bool) {
+	if _, busy := r.locks.LoadOrStore(id, true); busy {
+		return nil, false
+	}
+	return func() { r.locks.Delete(id) }, true
+}
+
+// Append writes a chunk that starts at offset. Whatever part of the chunk
+// arrived is kept even if the connection breaks, so the client can resume
+// from the new offset. The caller holds the lock.
+func (r *ResumableUploads) Append(upload *models.ResumableUpload, offset int64, chunk io.Reader) error {
+	if offset != upload.Offset {
+		return ErrOffsetMismatch
+	}
+
+	f, err := os.OpenFile(r.path(upload), os.O_WRONLY, 0644)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrUploadNotFound
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Drop bytes written after the last recorded offset, e.g. by a crash
+	if err := f.Truncate(offset); err != nil {
+		return err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	n, copyErr := io.Copy(f, io.LimitReader(chunk, upload.Length-offset))
+	if err := f.Sync(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+
+	upload.Offset = offset + n
+	upload.ExpiresAt = time.Now().Add(r.TTL)
+	if err := models.DB.Model(upload).Updates(map[string]interface{}{
+		"offset":     upload.Offset,
+		"expires_at": upload.ExpiresAt,
+	}).Error; err != nil {
+		return err
+	}
+	return copyErr
+}
+
+// Open returns the data received so far
+func (r *ResumableUploads) Open(upload *models.ResumableUpload) (*os.File, error) {
+	return os.Open(r.path(upload))
+}
+
+// Complete links the finished upload to its stored file and drops the
+// partial data. The record stays until it expires so clients can look up
+// the result.
+func (r *ResumableUploads) Complete(upload *models.ResumableUpload, stored *models.Upload) error {
+	upload.UploadID = &stored.ID
+	upload.Upload = stored
+	if err := models.DB.Model(upload).Update("upload_id", stored.ID).Error; err != nil {
+		return err
+	}
+	if err := os.Remove(r.path(upload)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("⚠️  Failed to remove partial upload %s: %v", upload.ID, err)
+	}
+	return nil
+}
+
+// Remove deletes an upload and its partial data
+func (r *ResumableUploads) Remove(upload *models.ResumableUpload) error {
+	if err := os.Remove(r.path(upload)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return models.DB.Delete(upload).Error
+}
+
+// Start launches the hourly cleanup of expired uploads
+func (r *ResumableUploads) Start() {
+	r.ticker = time.NewTicker(1 * time.Hour)
+
+	go func() {
+		r.removeExpired()
+
+		for {
+			select {
+			case <-r.done:
+				return
+			case <-r.ticker.C:
+				r.removeExpired()
+			}
+		}
+	}()
+
+	log.Printf("📦 Resumable uploads: %s (expire after %s)", r.Dir, r.TTL)
+}
+
+// Stop halts the cleanup
+func (r *ResumableUploads) Stop() {
+	if r.ticker != nil {
+		r.ticker.Stop()
+	}
+	r.done <- true
+}
+
+func (r *ResumableUploads) removeExpired() {
+	var uploads []models.ResumableUpload
+	if err := models.DB.Where("expires_at <= ?", time.Now()).Find(&uploads).Error; err != nil {
+		log.Printf("❌ Failed to find expired uploads: %v", err)
+		return
+	}
+
+	removed := 0
+	for i := range uploads {
+		unlock, ok := r.Lock(uploads[i].ID)
+		if !ok {
+			continue
+		}
+		if err := r.Remove(&uploads[i]); err != nil {
+			log.Printf("❌ Failed to remove expired upload %s: %v", uploads[i].ID, err)
+		} else {
+			removed++
+		}
+		unlock()
+	}
+
+	if removed > 0 {
+		log.Printf("🧹 Removed %d expired resumable uploads", removed)
+	}
+}
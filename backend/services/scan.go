@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"synergy_dms/models"
+	"synergy_dms/storage"
+)
+
+// ScanService scans uploaded files in the background. Files stay in
+// quarantine (pending) until the scanner reports them clean; files that could
+// not be scanned are retried periodically, up to maxScanAttempts times.
+type ScanService struct {
+	Scanner Scanner
+	Storage storage.Storage
+	Workers int
+	Timeout time.Duration
+
+	queue  chan uint
+	ticker *time.Ticker
+	done   chan bool
+}
+
+// NewScanService creates the service. A nil scanner disables scanning and
+// new files are released immediately.
+func NewScanService(scanner Scanner, store storage.Storage, workers int, timeout time.Duration) *ScanService {
+	if workers < 1 {
+		workers = 1
+	}
+	return &ScanService{
+		Scanner: scanner,
+		Storage: store,
+		Workers: workers,
+		Timeout: timeout,
+		queue:   make(chan uint, 1000),
+		done:    make(chan bool),
+	}
+}
+
+// Enabled reports whether uploads are scanned
+func (s *ScanService) Enabled() bool {
+	return s.Scanner != nil
+}
+
+// SetInitialStatus sets the verdict a new upload starts with. Content that
+// was already scanned under another upload keeps its verdict.
+func (s *ScanService) SetInitialStatus(upload *models.Upload) {
+	if !s.Enabled() {
+		upload.ScanStatus = models.ScanSkipped
+		return
+	}
+	upload.ScanStatus = models.ScanPending
+
+	var previous models.Upload
+	if err := models.DB.Where("checksum = ? AND scan_status IN ?", upload.Checksum,
+		[]models.ScanStatus{models.ScanClean, models.ScanInfected}).
+		Order("scanned_at DESC").First(&previous).Error; err == nil {
+		upload.ScanStatus = previous.ScanStatus
+		upload.ScanSignature = previous.ScanSignature
+		upload.ScannedAt = previous.ScannedAt
+	}
+}
+
+// Enqueue schedules an upload for scanning. When the queue is full the
+// periodic retry picks the file up later.
+func (s *ScanService) Enqueue(uploadID uint) {
+	if !s.Enabled() {
+		return
+	}
+	select {
+	case s.queue <- uploadID:
+	default:
+	}
+}
+
+// Start launches the workers and the retry loop
+func (s *ScanService) Start() {
+	if !s.Enabled() {
+		log.Println("⚠️  Antivirus scanning disabled (CLAMD_ADDRESS not set)")
+		return
+	}
+
+	for i := 0; i < s.Workers; i++ {
+		go func() {
+			for id := range s.queue {
+				s.scan(id)
+			}
+		}()
+	}
+
+	s.ticker = time.NewTicker(5 * time.Minute)
+	go func() {
+		// Pick up files left unscanned by a restart
+		s.requeue()
+
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-s.ticker.C:
+				s.requeue()
+			}
+		}
+	}()
+
+	log.Printf("🛡️  Antivirus scan service started (%d workers)", s.Workers)
+}
+
+// Stop halts the retry loop. Scans in progress finish on their own.
+func (s *ScanService) Stop() {
+	if !s.Enabled() {
+		return
+	}
+	s.ticker.Stop()
+	s.done <- true
+	log.Println("🛡️  Antivirus scan service stopped")
+}
+
+// A day of retries every 5 minutes
+const maxScanAttempts = 288
+
+// requeue schedules every upload that is still waiting for a verdict
+func (s *ScanService) requeue() {
+	var ids []uint
+	models.DB.Model(&models.Upload{}).
+		Where("scan_status IN ?", []models.ScanStatus{models.ScanPending, models.ScanFailed}).
+		Pluck("id", &ids)
+	for _, id := range ids {
+		s.Enqueue(id)
+	}
+}
+
+func (s *ScanService) scan(uploadID uint) {
+	var upload models.Upload
+	if err := models.DB.First(&upload, uploadID).Error; err != nil {
+		return
+	}
+	if upload.ScanStatus != models.ScanPending && upload.ScanStatus != models.ScanFailed {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+
+	status, signature, attempts := models.ScanFailed, "", upload.ScanAttempts
+	result, err := s.scanObject(ctx, upload.StorageKey())
+	switch {
+	case err != nil:
+		attempts++
+		log.Printf("❌ Failed to scan %s: %v", upload.StoredName, err)
+		// Files the scanner refuses stay quarantined for a super-admin to review
+		if errors.Is(err, ErrScanRefused) || attempts >= maxScanAttempts {
+			status = models.ScanError
+			models.RecordAudit(models.AuditFileScanError, nil, &upload.UploaderID, "",
+				fmt.Sprintf("%s (%s) could not be scanned after %d attempts: %v", upload.OriginalName, upload.StoredName, attempts, err))
+		}
+	case result.Clean:
+		status = models.ScanClean
+	default:
+		status, signature = models.ScanInfected, result.Signature
+		log.Printf("☣️  %s is infected: %s", upload.StoredName, signature)
+		models.RecordAudit(models.AuditFileInfected, nil, &upload.UploaderID, "",
+			fmt.Sprintf("%s (%s): %s", upload.OriginalName, upload.StoredName, signature))
+	}
+
+	now := time.Now()
+	models.DB.Model(&upload).Updates(map[string]interface{}{
+		"scan_status":    status,
+		"scan_signature": signature,
+		"scan_attempts":  attempts,
+		"scanned_at":     &now,
+	})
+	models.DB.Model(&models.DocumentAttachment{}).Where("stored_name = ?", upload.StoredName).
+		Updates(map[string]interface{}{
+			"scan_status":    status,
+			"scan_signature": signature,
+		})
+}
+
+// Rescan puts a file the scanner gave up on back into the queue, e.g. after
+// the scanner's limits were raised
+func (s *ScanService) Rescan(upload *models.Upload) error {
+	if err := models.DB.Model(upload).Updates(map[string]interface{}{
+		"scan_status":    models.ScanPending,
+		"scan_signature": "",
+		"scan_attempts":  0,
+	}).Error; err != nil {
+		return err
+	}
+	if err := models.DB.Model(&models.DocumentAttachment{}).Where("stored_name = ?", upload.StoredName).
+		Updates(map[string]interface{}{
+			"scan_status":    models.ScanPending,
+			"scan_signature": "",
+		}).Error; err != nil {
+		return err
+	}
+	s.Enqueue(upload.ID)
+	return nil
+}
+
+func (s *ScanService) scanObject(ctx context.Context, key string) (ScanResult, error) {
+	r, _, err := s.Storage.Get(ctx, key)
+	if err != nil {
+		return ScanResult{}, err
+	}
+	defer r.Close()
+	return s.Scanner.Scan(ctx, r)
+}
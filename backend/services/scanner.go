@@ -0,0 +1,163 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// ErrScanRefused is returned when the scanner rejects the file itself, so
+// retrying cannot help
+var ErrScanRefused = errors.New("scanner refused the file")
+
+// clamd errors caused by the file rather than the daemon's state
+var permanentClamdErrors = []string{"size limit exceeded"}
+
+// ScanResult is the verdict of an antivirus scan
+type ScanResult struct {
+	Clean     bool
+	Signature string // name of the detected threat
+}
+
+// Scanner checks file content for malware
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (ScanResult, error)
+}
+
+// ClamdScanner talks to a ClamAV daemon using the INSTREAM command
+type ClamdScanner struct {
+	Network   string // "tcp" or "unix"
+	Address   string
+	Timeout   time.Duration
+	ChunkSize int
+}
+
+// maxClamdReply bounds the daemon's answer
+const maxClamdReply = 4096
+
+// NewClamdScanner parses an address such as "tcp://clamav:3310",
+// "unix:///var/run/clamav/clamd.ctl" or plain "clamav:3310"
+func NewClamdScanner(address string, timeout time.Duration) (*ClamdScanner, error) {
+	network, addr := "tcp", address
+	switch {
+	case strings.HasPrefix(address, "tcp://"):
+		addr = strings.TrimPrefix(address, "tcp://")
+	case strings.HasPrefix(address, "unix://"):
+		network, addr = "unix", strings.TrimPrefix(address, "unix://")
+	}
+	if addr == "" {
+		return nil, fmt.Errorf("invalid clamd address %q", address)
+	}
+	return &ClamdScanner{Network: network, Address: addr, Timeout: timeout, ChunkSize: 64 * 1024}, nil
+}
+
+func (s *ClamdScanner) dial(ctx context.Context) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: s.Timeout}
+	return dialer.DialContext(ctx, s.Network, s.Address)
+}
+
+// readReply reads a NUL-terminated answer
+func readReply(conn net.Conn) (string, error) {
+	reply, err := bufio.NewReader(io.LimitReader(conn, maxClamdReply)).ReadString(0)
+	if err != nil && (err != io.EOF || reply == "") {
+		return "", err
+	}
+	return strings.TrimSpace(strings.TrimRight(reply, "\x00")), nil
+}
+
+// Ping checks that the daemon is reachable
+func (s *ClamdScanner) Ping(ctx context.Context) error {
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(s.Timeout))
+
+	if _, err := conn.Write([]byte("zPING\x00")); err != nil {
+		return err
+	}
+	reply, err := readReply(conn)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("clamd: unexpected reply %q", reply)
+	}
+	return nil
+}
+
+// Scan streams the content to clamd in length-prefixed chunks and parses
+// the verdict, e.g. "stream: OK" or "stream: Eicar-Test-Signature FOUND"
+func (s *ClamdScanner) Scan(ctx context.Context, r io.Reader) (ScanResult, error) {
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return ScanResult{}, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	writeErr := s.stream(conn, r)
+
+	// clamd may close the stream early (e.g. size limit) and still answer
+	conn.SetReadDeadline(time.Now().Add(s.Timeout))
+	reply, err := readReply(conn)
+	if err != nil || reply == "" {
+		if writeErr != nil {
+			return ScanResult{}, writeErr
+		}
+		return ScanResult{}, errors.New("clamd: no reply")
+	}
+
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return ScanResult{Clean: true}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return ScanResult{Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	}
+	for _, permanent := range permanentClamdErrors {
+		if strings.Contains(reply, permanent) {
+			return ScanResult{}, fmt.Errorf("%w: clamd: %s", ErrScanRefused, reply)
+		}
+	}
+	return ScanResult{}, fmt.Errorf("clamd: %s", reply)
+}
+
+func (s *ClamdScanner) stream(conn net.Conn, r io.Reader) error {
+	conn.SetWriteDeadline(time.Now().Add(s.Timeout))
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 4+s.ChunkSize)
+	for {
+		n, err := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			conn.SetWriteDeadline(time.Now().Add(s.Timeout))
+			if _, werr := conn.Write(buf[:4+n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// A zero-length chunk ends the stream
+	_, err := conn.Write([]byte{0, 0, 0, 0})
+	return err
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"synergy_dms/config"
+)
+
+// URLSigner issues and checks short-lived download links. A link is bound to
+// one file of one document and stops working after its expiry time.
+type URLSigner struct {
+	Key []byte
+	TTL time.Duration
+}
+
+func NewURLSigner(cfg *config.Config) *URLSigner {
+	key := cfg.DownloadSigningKey
+	if key == "" {
+		// Derived from the JWT secret so links cannot be forged as tokens
+		key = "download:" + cfg.JWTSecret
+	}
+	return &URLSigner{Key: []byte(key), TTL: cfg.DownloadURLTTL}
+}
+
+func (s *URLSigner) signature(docID uint, fileID string, expires int64) string {
+	mac := hmac.New(sha256.New, s.Key)
+	fmt.Fprintf(mac, "%d\n%s\n%d", docID, fileID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign returns the expiry time and signature of a link to the file
+func (s *URLSigner) Sign(docID uint, fileID string, now time.Time) (time.Time, string) {
+	expires := now.Add(s.TTL).Truncate(time.Second)
+	return expires, s.signature(docID, fileID, expires.Unix())
+}
+
+// Verify reports whether the signature is valid and not expired
+func (s *URLSigner) Verify(docID uint, fileID string, expires int64, signature string, now time.Time) bool {
+	if now.Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(s.signature(docID, fileID, expires)))
+}
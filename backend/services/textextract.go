@@ -0,0 +1,191 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Limits for text extraction
+const (
+	// Longer texts are cut; search still finds the beginning of the file
+	maxExtractedText   = 200000
+	maxZipPartSize     = 32 * 1024 * 1024
+	maxIndexedFileSize = 64 * 1024 * 1024
+)
+
+var errTextUnsupported = errors.New("text extraction not supported")
+
+// Types whose text is indexed for search
+const (
+	mimeDOCX = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	mimeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+var textExtractableTypes = []string{"application/pdf", mimeDOCX, mimeXLSX, "text/plain"}
+
+func textExtractable(mimeType string) bool {
+	for _, t := range textExtractableTypes {
+		if t == mimeType {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractText returns the plain text of a PDF, DOCX, XLSX or TXT file
+func ExtractText(data []byte, mimeType string) (string, error) {
+	var text string
+	var err error
+	switch mimeType {
+	case "application/pdf":
+		text, err = pdfExtractText(data, maxExtractedText)
+	case mimeDOCX:
+		text, err = ooxmlText(data, func(name string) bool { return name == "word/document.xml" })
+	case mimeXLSX:
+		text, err = ooxmlText(data, func(name string) bool {
+			return name == "xl/sharedStrings.xml" ||
+				(strings.HasPrefix(name, "xl/worksheets/") && strings.HasSuffix(name, ".xml"))
+		})
+	case "text/plain":
+		text = plainTextContent(data)
+	default:
+		return "", errTextUnsupported
+	}
+	if err != nil {
+		return "", err
+	}
+	return truncateRunes(normalizeSpace(text), maxExtractedText), nil
+}
+
+// plainTextContent decodes UTF-8, falling back to Windows-1251 which older
+// Russian text files use
+func plainTextContent(data []byte) string {
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	text, err := charmap.Windows1251.NewDecoder().Bytes(data)
+	if err != nil {
+		return ""
+	}
+	return string(text)
+}
+
+// ooxmlText collects the text runs (<t> elements) of the selected parts of
+// an Office Open XML package
+func ooxmlText(data []byte, wanted func(name string) bool) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil || len(zr.File) > maxZipEntries {
+		return "", ErrMalformedDocument
+	}
+
+	var parts []*zip.File
+	for _, f := range zr.File {
+		if wanted(f.Name) {
+			parts = append(parts, f)
+		}
+	}
+	// Shared strings first, then sheets in order
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Name < parts[j].Name })
+
+	var out strings.Builder
+	for _, part := range parts {
+		if out.Len() >= maxExtractedText*4 {
+			break
+		}
+		rc, err := part.Open()
+		if err != nil {
+			return "", ErrMalformedDocument
+		}
+		err = xmlText(io.LimitReader(rc, maxZipPartSize), &out)
+		rc.Close()
+		if err != nil {
+			return "", ErrMalformedDocument
+		}
+	}
+	return out.String(), nil
+}
+
+// xmlText writes the character data of <t> elements and of cell values
+// that are not shared string indexes; paragraphs, rows, cells, tabs and
+// breaks become whitespace
+func xmlText(r io.Reader, out *strings.Builder) error {
+	dec := xml.NewDecoder(r)
+	inText, sharedCell := false, false
+	for out.Len() < maxExtractedText*4 {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "v":
+				inText = !sharedCell
+			case "c":
+				sharedCell = false
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "t" && attr.Value == "s" {
+						sharedCell = true
+					}
+				}
+				out.WriteString(" ")
+			case "tab", "br":
+				out.WriteString(" ")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t", "v":
+				inText = false
+			case "p", "row", "si":
+				out.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				out.Write(t)
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeSpace collapses runs of blanks and drops control characters
+// PostgreSQL can not store
+func normalizeSpace(s string) string {
+	var out strings.Builder
+	out.Grow(len(s))
+	space, newline := false, false
+	for _, r := range s {
+		switch {
+		case r == '\n' || r == '\r' || r == '\f':
+			newline = true
+		case r == ' ' || r == '\t' || r == 0xA0 || r < 0x20 || r == utf8.RuneError:
+			space = true
+		default:
+			if out.Len() > 0 {
+				if newline {
+					out.WriteByte('\n')
+				} else if space {
+					out.WriteByte(' ')
+				}
+			}
+			space, newline = false, false
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
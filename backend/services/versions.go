@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"synergy_dms/models"
+	"synergy_dms/storage"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotUploaded is returned for file paths that do not point at an uploaded file
+	ErrNotUploaded = errors.New("file was not uploaded")
+	// ErrUploadNotOwned is returned when a user refers to a file someone else uploaded
+	ErrUploadNotOwned = errors.New("file was uploaded by another user")
+)
+
+// Versioner records file revisions of documents kept in the file storage
+type Versioner struct {
+	Storage storage.Storage
+}
+
+func NewVersioner(store storage.Storage) *Versioner {
+	return &Versioner{Storage: store}
+}
+
+// StorageKey turns a "/uploads/<name>" reference into a storage key
+func StorageKey(fileURL string) (string, error) {
+	key := strings.TrimPrefix(fileURL, models.UploadURLPrefix)
+	if key == fileURL || key == "" || strings.ContainsAny(key, "/\\") {
+		return "", ErrNotUploaded
+	}
+	return key, nil
+}
+
+// Inspect returns the size and SHA-256 checksum of an uploaded file. Unless
+// actor is nil (system use) or a super-admin, the file must be one actor
+// uploaded.
+func (v *Versioner) Inspect(fileURL string, actor *models.User) (int64, string, error) {
+	key, err := StorageKey(fileURL)
+	if err != nil {
+		return 0, "", err
+	}
+
+	upload := models.FindUpload(key)
+	if actor != nil && actor.Role != models.RoleSuperAdmin {
+		// Files stored before uploads were recorded have no owner
+		if upload == nil {
+			return 0, "", ErrNotUploaded
+		}
+		if upload.UploaderID != actor.ID {
+			return 0, "", ErrUploadNotOwned
+		}
+	}
+
+	// Deduplicated uploads were hashed when they arrived
+	if upload != nil {
+		if upload.Checksum != "" {
+			return upload.Size, upload.Checksum, nil
+		}
+		key = upload.StorageKey()
+	}
+
+	r, _, err := v.Storage.Get(context.Background(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return 0, "", ErrNotUploaded
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	defer r.Close()
+
+	checksum, size, err := HashContent(r)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, checksum, nil
+}
+
+// AddVersion records fileURL, uploaded by uploader, as the next revision of
+// the document and makes it the current file. The caller saves the document.
+func (v *Versioner) AddVersion(tx *gorm.DB, doc *models.Document, fileURL string, uploader *models.User,
+	comment string) (*models.DocumentVersion, error) {
+	size, checksum, err := v.Inspect(fileURL, uploader)
+	if err != nil {
+		return nil, err
+	}
+	return models.AddDocumentVersion(tx, doc, fileURL, size, checksum, uploader.ID, comment)
+}
+
+// Backfill creates the first version for documents that got their file
+// before versioning existed. Missing files are skipped.
+func (v *Versioner) Backfill() error {
+	var documents []models.Document
+	if err := models.DB.Where("file_path <> '' AND current_version = 0").Find(&documents).Error; err != nil {
+		return err
+	}
+
+	for i := range documents {
+		doc := &documents[i]
+		err := models.DB.Transaction(func(tx *gorm.DB) error {
+			size, checksum, err := v.Inspect(doc.FilePath, nil)
+			if err != nil {
+				return err
+			}
+			if _, err := models.AddDocumentVersion(tx, doc, doc.FilePath, size, checksum, doc.CreatorID,
+				"Imported from existing file"); err != nil {
+				return err
+			}
+			return tx.Model(doc).UpdateColumns(map[string]interface{}{
+				"file_path":       doc.FilePath,
+				"current_version": doc.CurrentVersion,
+			}).Error
+		})
+		if errors.Is(err, ErrNotUploaded) {
+			log.Printf("⚠️  Document %d: file %s not found, no version created", doc.ID, doc.FilePath)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(documents) > 0 {
+		log.Printf("📄 Backfilled versions for %d documents", len(documents))
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"synergy_dms/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestInspectRejectsOtherUsersUploads(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Upload{}); err != nil {
+		t.Fatal(err)
+	}
+	models.DB = db
+
+	owner := &models.User{ID: 1, Role: models.RoleStudent}
+	upload := models.Upload{
+		StoredName: "20240101_120000_abcd1234.pdf",
+		Size:       42,
+		Checksum:   "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		UploaderID: owner.ID,
+		ScanStatus: models.ScanClean,
+	}
+	if err := db.Create(&upload).Error; err != nil {
+		t.Fatal(err)
+	}
+	fileURL := models.UploadURLPrefix + upload.StoredName
+
+	v := NewVersioner(nil)
+	tests := []struct {
+		name    string
+		fileURL string
+		actor   *models.User
+		want    error
+	}{
+		{"owner", fileURL, owner, nil},
+		{"another student", fileURL, &models.User{ID: 2, Role: models.RoleStudent}, ErrUploadNotOwned},
+		{"admin", fileURL, &models.User{ID: 3, Role: models.RoleAdmin}, ErrUploadNotOwned},
+		{"super-admin", fileURL, &models.User{ID: 4, Role: models.RoleSuperAdmin}, nil},
+		{"system", fileURL, nil, nil},
+		{"unrecorded file", models.UploadURLPrefix + "legacy.pdf", owner, ErrNotUploaded},
+	}
+	for _, tt := range tests {
+		size, _, err := v.Inspect(tt.fileURL, tt.actor)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: got error %v, want %v", tt.name, err, tt.want)
+			continue
+		}
+		if err == nil && size != upload.Size {
+			t.Errorf("%s: got size %d, want %d", tt.name, size, upload.Size)
+		}
+	}
+}
@@ -0,0 +1,393 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"synergy_dms/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNotAwaitingApproval = errors.New("document is not awaiting approval")
+	ErrNotStepApprover     = errors.New("you are not an approver for the current step")
+	ErrWorkflowInactive    = errors.New("workflow is not active")
+	ErrWorkflowEmpty       = errors.New("workflow has no steps")
+	ErrAlreadyVoted        = errors.New("you have already voted on this step")
+)
+
+// WorkflowEngine moves documents through the steps of their workflow definition
+type WorkflowEngine struct{}
+
+func NewWorkflowEngine() *WorkflowEngine {
+	return &WorkflowEngine{}
+}
+
+// LoadWorkflow returns the document's workflow with ordered steps. Deleted
+// definitions are still loaded so documents already in flight can finish.
+func (e *WorkflowEngine) LoadWorkflow(workflowID uint) (*models.WorkflowDefinition, error) {
+	return e.loadWorkflow(models.DB, workflowID)
+}
+
+func (e *WorkflowEngine) loadWorkflow(db *gorm.DB, workflowID uint) (*models.WorkflowDefinition, error) {
+	var workflow models.WorkflowDefinition
+	if err := models.PreloadWorkflowSteps(db.Unscoped()).First(&workflow, workflowID).Error; err != nil {
+		return nil, err
+	}
+	return &workflow, nil
+}
+
+// CurrentStep returns the step the document is waiting on
+func (e *WorkflowEngine) CurrentStep(doc *models.Document) (*models.WorkflowStep, *models.WorkflowDefinition, error) {
+	if doc.WorkflowID == nil || doc.Status != models.StatusPending {
+		return nil, nil, ErrNotAwaitingApproval
+	}
+
+	workflow, err := e.LoadWorkflow(*doc.WorkflowID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range workflow.Steps {
+		if workflow.Steps[i].Position == doc.CurrentStep {
+			return &workflow.Steps[i], workflow, nil
+		}
+	}
+	return nil, nil, ErrNotAwaitingApproval
+}
+
+// CanAct reports whether the user may decide on the document's current step.
+// Super-admins can always act on single-approver steps.
+func (e *WorkflowEngine) CanAct(doc *models.Document, user *models.User) bool {
+	step, _, err := e.CurrentStep(doc)
+	if err != nil {
+		return false
+	}
+	return step.CanApprove(user) || (user.Role == models.RoleSuperAdmin && !step.IsParallel())
+}
+
+// Start puts a newly created document on the first step of the workflow
+func (e *WorkflowEngine) Start(tx *gorm.DB, doc *models.Document, workflowID uint, actorID uint) error {
+	workflow, err := e.LoadWorkflow(workflowID)
+	if err != nil {
+		return err
+	}
+	if !workflow.IsActive || workflow.DeletedAt.Valid {
+		return ErrWorkflowInactive
+	}
+	if len(workflow.Steps) == 0 {
+		return ErrWorkflowEmpty
+	}
+
+	doc.WorkflowID = &workflow.ID
+	return e.enterStep(tx, doc, &workflow.Steps[0], actorID,
+		fmt.Sprintf("Workflow %q started at step 1: %s", workflow.Name, workflow.Steps[0].Name))
+}
+
+// Restart sends a document back to the first step of its workflow
+func (e *WorkflowEngine) Restart(tx *gorm.DB, doc *models.Document, actorID uint, comment string) error {
+	if doc.WorkflowID == nil {
+		return nil
+	}
+
+	workflow, err := e.LoadWorkflow(*doc.WorkflowID)
+	if err != nil {
+		return err
+	}
+	if len(workflow.Steps) == 0 {
+		return ErrWorkflowEmpty
+	}
+
+	doc.Status = models.StatusPending
+	return e.enterStep(tx, doc, &workflow.Steps[0], actorID, comment)
+}
+
+// Outcome describes what a decision did to a document
+type Outcome string
+
+const (
+	OutcomeVoteRecorded Outcome = "vote_recorded"
+	OutcomeStepAdvanced Outcome = "step_advanced"
+	OutcomeApproved     Outcome = "approved"
+	OutcomeRejected     Outcome = "rejected"
+)
+
+// Approve signs off the current step. The document moves to the next step,
+// or is approved when the last step is done. On parallel steps the approval
+// is recorded as a vote and the step only completes once the quorum is met.
+func (e *WorkflowEngine) Approve(doc *models.Document, user *models.User, comment string) (Outcome, error) {
+	return e.decide(doc, user, true, comment)
+}
+
+// Reject rejects the document at its current step. On parallel steps the
+// rejection is recorded as a vote and the document is only rejected once
+// the quorum can no longer be reached.
+func (e *WorkflowEngine) Reject(doc *models.Document, user *models.User, reason string) (Outcome, error) {
+	return e.decide(doc, user, false, reason)
+}
+
+func (e *WorkflowEngine) decide(doc *models.Document, user *models.User, approve bool, comment string) (Outcome, error) {
+	var outcome Outcome
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		// Concurrent decisions on the document wait here and then see the
+		// step and votes the previous one left behind
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(doc, doc.ID).Error; err != nil {
+			return err
+		}
+		step, workflow, err := e.CurrentStep(doc)
+		if err != nil {
+			return err
+		}
+
+		// Super-admins may override single-approver steps, but votes on parallel
+		// steps belong to the listed approvers only
+		if !step.CanApprove(user) && (step.IsParallel() || user.Role != models.RoleSuperAdmin) {
+			return ErrNotStepApprover
+		}
+
+		if step.IsParallel() {
+			outcome, err = e.vote(tx, doc, workflow, step, user, approve, comment)
+			return err
+		}
+
+		if !approve {
+			outcome = OutcomeRejected
+			return e.reject(tx, doc, step, user.ID,
+				fmt.Sprintf("Rejected at step %d (%s): %s", step.Position, step.Name, comment), comment)
+		}
+
+		note := fmt.Sprintf("Step %d (%s) approved", step.Position, step.Name)
+		if comment != "" {
+			note += ": " + comment
+		}
+		if err := tx.Create(&models.History{
+			DocumentID: doc.ID,
+			Version:    doc.CurrentVersion,
+			ActorID:    user.ID,
+			Action:     models.ActionStepApproved,
+			Comment:    note,
+			StepID:     &step.ID,
+		}).Error; err != nil {
+			return err
+		}
+
+		outcome, err = e.advance(tx, doc, workflow, step, user.ID)
+		return err
+	})
+
+	return outcome, err
+}
+
+// vote records one approver's vote on a parallel step and completes the step
+// when the quorum is met or can no longer be reached
+func (e *WorkflowEngine) vote(tx *gorm.DB, doc *models.Document, workflow *models.WorkflowDefinition,
+	step *models.WorkflowStep, user *models.User, approve bool, comment string) (Outcome, error) {
+	var previous int64
+	if err := stepVotes(tx, doc, step).Where("actor_id = ?", user.ID).Count(&previous).Error; err != nil {
+		return "", err
+	}
+	if previous > 0 {
+		return "", ErrAlreadyVoted
+	}
+
+	action := models.ActionVoteApproved
+	note := fmt.Sprintf("Voted to approve step %d (%s)", step.Position, step.Name)
+	if !approve {
+		action = models.ActionVoteRejected
+		note = fmt.Sprintf("Voted to reject step %d (%s)", step.Position, step.Name)
+	}
+	if comment != "" {
+		note += ": " + comment
+	}
+	if err := tx.Create(&models.History{
+		DocumentID: doc.ID,
+		Version:    doc.CurrentVersion,
+		ActorID:    user.ID,
+		Action:     action,
+		Comment:    note,
+		StepID:     &step.ID,
+	}).Error; err != nil {
+		return "", err
+	}
+
+	return e.settle(tx, doc, workflow, step, user.ID, comment)
+}
+
+// stepVotes selects the votes cast on the document's current step
+func stepVotes(tx *gorm.DB, doc *models.Document, step *models.WorkflowStep) *gorm.DB {
+	votes := tx.Model(&models.History{}).
+		Where("document_id = ? AND step_id = ? AND action IN ?", doc.ID, step.ID,
+			[]models.ActionType{models.ActionVoteApproved, models.ActionVoteRejected})
+	if doc.StepStartedAt != nil {
+		votes = votes.Where("timestamp >= ?", *doc.StepStartedAt)
+	}
+	return votes
+}
+
+// settle completes a parallel step when the votes of its listed approvers
+// meet the quorum, or rejects the document with reason once the quorum can
+// no longer be reached
+func (e *WorkflowEngine) settle(tx *gorm.DB, doc *models.Document, workflow *models.WorkflowDefinition,
+	step *models.WorkflowStep, actorID uint, reason string) (Outcome, error) {
+	// Votes of approvers removed from the step no longer count
+	approverIDs := make([]uint, 0, len(step.Approvers))
+	for _, approver := range step.Approvers {
+		approverIDs = append(approverIDs, approver.ID)
+	}
+	votes := stepVotes(tx, doc, step).Where("actor_id IN ?", approverIDs)
+
+	var approvals, rejections int64
+	if err := votes.Session(&gorm.Session{}).Where("action = ?", models.ActionVoteApproved).Count(&approvals).Error; err != nil {
+		return "", err
+	}
+	if err := votes.Session(&gorm.Session{}).Where("action = ?", models.ActionVoteRejected).Count(&rejections).Error; err != nil {
+		return "", err
+	}
+
+	total := int64(len(step.Approvers))
+	quorum := int64(step.Quorum())
+
+	switch {
+	case total > 0 && approvals >= quorum:
+		if err := tx.Create(&models.History{
+			DocumentID: doc.ID,
+			Version:    doc.CurrentVersion,
+			ActorID:    actorID,
+			Action:     models.ActionStepApproved,
+			Comment:    fmt.Sprintf("Step %d (%s) approved: %d of %d approvals", step.Position, step.Name, approvals, total),
+			StepID:     &step.ID,
+		}).Error; err != nil {
+			return "", err
+		}
+		return e.advance(tx, doc, workflow, step, actorID)
+
+	case total == 0 || total-rejections < quorum:
+		note := fmt.Sprintf("Quorum unreachable at step %d (%s): %d of %d approvers rejected, %d approvals required",
+			step.Position, step.Name, rejections, total, quorum)
+		if err := e.reject(tx, doc, step, actorID, note, reason); err != nil {
+			return "", err
+		}
+		return OutcomeRejected, nil
+	}
+
+	return OutcomeVoteRecorded, nil
+}
+
+// RemoveApprover takes a user who can no longer approve off every parallel
+// step listing them. Documents waiting on such a step are settled with the
+// remaining approvers: they move on if the quorum is already met and are
+// rejected with reason if it can no longer be reached.
+func (e *WorkflowEngine) RemoveApprover(tx *gorm.DB, userID, actorID uint, reason string) error {
+	var stepIDs []uint
+	if err := tx.Table("workflow_step_approvers").Where("user_id = ?", userID).
+		Pluck("workflow_step_id", &stepIDs).Error; err != nil {
+		return err
+	}
+	if len(stepIDs) == 0 {
+		return nil
+	}
+	if err := tx.Exec("DELETE FROM workflow_step_approvers WHERE user_id = ?", userID).Error; err != nil {
+		return err
+	}
+
+	var documentIDs []uint
+	if err := tx.Table("documents").
+		Joins("JOIN workflow_steps ON workflow_steps.workflow_id = documents.workflow_id AND workflow_steps.position = documents.current_step").
+		Where("documents.status = ? AND documents.deleted_at IS NULL", models.StatusPending).
+		Where("workflow_steps.id IN ?", stepIDs).
+		Pluck("documents.id", &documentIDs).Error; err != nil {
+		return err
+	}
+
+	for _, id := range documentIDs {
+		var doc models.Document
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&doc, id).Error; err != nil {
+			return err
+		}
+		workflow, err := e.loadWorkflow(tx, *doc.WorkflowID)
+		if err != nil {
+			return err
+		}
+		for i := range workflow.Steps {
+			if step := &workflow.Steps[i]; step.Position == doc.CurrentStep && step.IsParallel() {
+				if _, err := e.settle(tx, &doc, workflow, step, actorID, reason); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// advance moves the document past a completed step
+func (e *WorkflowEngine) advance(tx *gorm.DB, doc *models.Document, workflow *models.WorkflowDefinition,
+	step *models.WorkflowStep, actorID uint) (Outcome, error) {
+	for i := range workflow.Steps {
+		if next := &workflow.Steps[i]; next.Position > step.Position {
+			err := e.enterStep(tx, doc, next, actorID, fmt.Sprintf("Moved to step %d: %s", next.Position, next.Name))
+			return OutcomeStepAdvanced, err
+		}
+	}
+
+	doc.Status = models.StatusApproved
+	if err := tx.Omit(clause.Associations).Save(doc).Error; err != nil {
+		return "", err
+	}
+	err := tx.Create(&models.History{
+		DocumentID: doc.ID,
+		Version:    doc.CurrentVersion,
+		ActorID:    actorID,
+		Action:     models.ActionApproved,
+		Comment:    fmt.Sprintf("Document approved: workflow %q completed", workflow.Name),
+		StepID:     &step.ID,
+	}).Error
+	return OutcomeApproved, err
+}
+
+// reject finalizes the document as rejected at the given step
+func (e *WorkflowEngine) reject(tx *gorm.DB, doc *models.Document, step *models.WorkflowStep,
+	actorID uint, comment, reason string) error {
+	doc.Status = models.StatusRejected
+	doc.RejectionReason = reason
+	if err := tx.Omit(clause.Associations).Save(doc).Error; err != nil {
+		return err
+	}
+	return tx.Create(&models.History{
+		DocumentID: doc.ID,
+		Version:    doc.CurrentVersion,
+		ActorID:    actorID,
+		Action:     models.ActionRejected,
+		Comment:    comment,
+		StepID:     &step.ID,
+	}).Error
+}
+
+// enterStep moves the document onto a step. Steps bound to a specific user
+// are assigned to that user while they are an active admin; other steps,
+// including parallel ones, return to the unassigned pool.
+func (e *WorkflowEngine) enterStep(tx *gorm.DB, doc *models.Document, step *models.WorkflowStep, actorID uint, comment string) error {
+	now := time.Now()
+	doc.CurrentStep = step.Position
+	doc.StepStartedAt = &now
+	doc.AssignedToID = nil
+	if !step.IsParallel() && step.ApproverUser != nil && step.ApproverUser.IsActiveAdmin() {
+		doc.AssignedToID = step.ApproverUserID
+	}
+	doc.AssignedTo = nil
+
+	if err := tx.Omit(clause.Associations).Save(doc).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(&models.History{
+		DocumentID: doc.ID,
+		Version:    doc.CurrentVersion,
+		ActorID:    actorID,
+		Action:     models.ActionStepStarted,
+		Comment:    comment,
+		StepID:     &step.ID,
+	}).Error
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"synergy_dms/config"
+)
+
+var (
+	// ErrNotFound is returned when the object does not exist
+	ErrNotFound = errors.New("object not found")
+	// ErrSignedURLUnsupported is returned by backends that cannot issue
+	// direct download links; callers fall back to proxying the file
+	ErrSignedURLUnsupported = errors.New("signed URLs are not supported by this backend")
+	// ErrInvalidKey is returned for keys that could escape the storage root
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// Object describes a stored file
+type Object struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// SignedURLOptions controls the response of a direct download link
+type SignedURLOptions struct {
+	TTL                time.Duration
+	ContentType        string
+	ContentDisposition string
+}
+
+// Storage keeps uploaded files. Keys are flat names such as
+// "20240101_120000_abcd1234.pdf".
+type Storage interface {
+	// Put stores the content under key, replacing any existing object
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object for reading. The caller closes the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, *Object, error)
+	// Delete removes the object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+	// Stat returns the object metadata
+	Stat(ctx context.Context, key string) (*Object, error)
+	// SignedURL returns a time-limited link that downloads the object directly
+	SignedURL(ctx context.Context, key string, opts SignedURLOptions) (string, error)
+	// List calls fn for every stored object
+	List(ctx context.Context, fn func(Object) error) error
+}
+
+// Backend names accepted by STORAGE_BACKEND
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+// New creates the storage backend with the given name from the config
+func New(cfg *config.Config, backend string) (Storage, error) {
+	switch backend {
+	case BackendLocal, "":
+		return NewLocal(cfg.UploadDir)
+	case BackendS3:
+		return NewS3(S3Options{
+			Endpoint:     cfg.S3Endpoint,
+			Region:       cfg.S3Region,
+			Bucket:       cfg.S3Bucket,
+			AccessKey:    cfg.S3AccessKey,
+			SecretKey:    cfg.S3SecretKey,
+			UsePathStyle: cfg.S3UsePathStyle,
+		})
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", backend)
+}
+
+// validKey rejects keys with path separators or relative segments
+func validKey(key string) bool {
+	if key == "" || key == "." || key == ".." {
+		return false
+	}
+	for _, r := range key {
+		if r == '/' || r == '\\' || r == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -256,4 +256,17 @@ class DocumentController extends GetxController {
   String getFileUrl(String path) {
     return _apiService.getFileUrl(path);
   }
+  
+  // Files are not public, so a signed link is requested right before opening
+  Future<String?> getDocumentFileUrl(int documentId) async {
+    try {
+      final response = await _apiService.getFileLink(documentId);
+      if (response.data['success'] == true) {
+        return getFileUrl(response.data['data']['url']);
+      }
+    } catch (e) {
+      Get.snackbar('Ошибка', 'Не удалось открыть файл', snackPosition: SnackPosition.BOTTOM);
+    }
+    return null;
+  }
 }
@@ -131,7 +131,7 @@ class _DocumentDetailScreenState extends State<DocumentDetailScreen> {
 
   Widget _buildFileCard(doc) {
     return GestureDetector(
-      onTap: () => _openFile(doc.filePath),
+      onTap: () => _openFile(doc.id),
       child: Container(
         padding: const EdgeInsets.all(16),
         decoration: BoxDecoration(color: AppTheme.primaryColor.withOpacity(0.1), borderRadius: BorderRadius.circular(12)),
@@ -253,8 +253,10 @@ class _DocumentDetailScreenState extends State<DocumentDetailScreen> {
     );
   }
 
-  void _openFile(String path) async {
-    final url = Uri.parse(_docController.getFileUrl(path));
+  void _openFile(int documentId) async {
+    final link = await _docController.getDocumentFileUrl(documentId);
+    if (link == null) return;
+    final url = Uri.parse(link);
     if (await canLaunchUrl(url)) launchUrl(url, mode: LaunchMode.externalApplication);
   }
 
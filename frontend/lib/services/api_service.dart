@@ -118,7 +118,7 @@ class ApiService {
   }
   
   Future<Response> getAdmins() async {
-    return await _dio.get('/users/admins');
+    return await _getAllPages('/users/admins');
   }
   
   // Document Endpoints
@@ -126,7 +126,7 @@ class ApiService {
     Map<String, dynamic> params = {};
     if (status != null) params['status'] = status;
     if (priority != null) params['priority'] = priority;
-    return await _dio.get('/documents', queryParameters: params);
+    return await _getAllPages('/documents', queryParameters: params);
   }
   
   Future<Response> getDocument(int id) async {
@@ -161,7 +161,7 @@ class ApiService {
   }
   
   Future<Response> getDocumentHistory(int id) async {
-    return await _dio.get('/documents/$id/history');
+    return await _getAllPages('/documents/$id/history');
   }
   
   // Upload Endpoint
@@ -180,6 +180,30 @@ class ApiService {
     return await _dio.post('/api/upload', data: formData);
   }
   
+  // Short-lived signed link to a document file ('primary', 'v<N>' or attachment ID)
+  Future<Response> getFileLink(int documentId, {String fileId = 'primary'}) async {
+    return await _dio.post('/documents/$documentId/files/$fileId/link');
+  }
+  
+  // Lists are paged: follows next_cursor until every page is loaded and
+  // returns the last response with the rows of all pages in 'data'
+  Future<Response> _getAllPages(String path, {Map<String, dynamic>? queryParameters}) async {
+    final params = <String, dynamic>{...?queryParameters, 'limit': AppConstants.pageSize};
+    final items = <dynamic>[];
+    while (true) {
+      final response = await _dio.get(path, queryParameters: params);
+      if (response.data['success'] != true) return response;
+      items.addAll(response.data['data'] ?? []);
+      final next = response.data['next_cursor'];
+      if (next == null || next == '') {
+        response.data['data'] = items;
+        response.data['count'] = items.length;
+        return response;
+      }
+      params['cursor'] = next;
+    }
+  }
+  
   // Get file URL
   String getFileUrl(String filePath) {
     if (filePath.startsWith('http')) {
@@ -11,6 +11,9 @@ class AppConstants {
   // Polling Interval
   static const Duration pollInterval = Duration(seconds: 5);
   
+  // Rows per request when loading paged lists (server maximum)
+  static const int pageSize = 200;
+  
   // Priorities
   static const Map<int, String> priorityLabels = {
     1: 'Низкий',